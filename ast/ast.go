@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/dikaeinstein/monkey/token"
@@ -12,6 +13,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character belonging to the node
+	End() token.Position // position of the first character immediately after the node
 }
 
 // Statement describes a statement node
@@ -39,6 +42,18 @@ func (p *Program) TokenLiteral() string {
 
 	return ""
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+	return token.Position{}
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -59,6 +74,13 @@ type LetStatement struct {
 // TokenLiteral returns the 'LetStatement' node token literal
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	return ls.Name.End()
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -75,6 +97,108 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// DestructuringLetStatement represents a let statement binding the
+// elements of an array, `let [a, b, ...rest] = value;`, or the values of a
+// hash by key, `let {a, b} = value;`
+type DestructuringLetStatement struct {
+	Token token.Token // the token.LET token
+	Open  token.Token // the '[' or '{' token
+	Names []*Identifier
+	Rest  *Identifier // the `...rest` name of an array pattern, if any
+	Value Expression
+}
+
+func (dl *DestructuringLetStatement) statementNode()       {}
+func (dl *DestructuringLetStatement) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DestructuringLetStatement) Pos() token.Position  { return dl.Token.Pos }
+func (dl *DestructuringLetStatement) End() token.Position  { return dl.Value.End() }
+
+// IsHash reports whether the statement destructures a hash
+func (dl *DestructuringLetStatement) IsHash() bool {
+	return dl.Open.Type == token.LBRACE
+}
+
+func (dl *DestructuringLetStatement) String() string {
+	var out bytes.Buffer
+
+	names := []string{}
+	for _, name := range dl.Names {
+		names = append(names, name.Value)
+	}
+	if dl.Rest != nil {
+		names = append(names, "..."+dl.Rest.Value)
+	}
+
+	out.WriteString(dl.TokenLiteral() + " ")
+	if dl.IsHash() {
+		out.WriteString("{" + strings.Join(names, ", ") + "}")
+	} else {
+		out.WriteString("[" + strings.Join(names, ", ") + "]")
+	}
+	out.WriteString(" = ")
+	out.WriteString(dl.Value.String())
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// ImportStatement represents an `import "path" as name;` statement
+type ImportStatement struct {
+	Token token.Token // the token.IMPORT token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position  { return is.Name.End() }
+func (is *ImportStatement) String() string {
+	return fmt.Sprintf("%s %q as %s;", is.TokenLiteral(), is.Path.Value, is.Name.Value)
+}
+
+// File returns the path of the imported module. A relative path is resolved
+// against the directory of the importing file.
+func (is *ImportStatement) File() string {
+	path := is.Path.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(is.Token.Pos.Filename), path)
+	}
+	return filepath.Clean(path)
+}
+
+// ExportStatement represents an `export let ...;` or `export type ...`
+// statement. The names bound by the statement are exported from the module.
+type ExportStatement struct {
+	Token     token.Token // the token.EXPORT token
+	Statement Statement   // a *LetStatement, *DestructuringLetStatement or *TypeStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) End() token.Position  { return es.Statement.End() }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// Names returns the names exported by the statement
+func (es *ExportStatement) Names() []*Identifier {
+	switch stmt := es.Statement.(type) {
+	case *LetStatement:
+		return []*Identifier{stmt.Name}
+	case *TypeStatement:
+		return []*Identifier{stmt.Name}
+	case *DestructuringLetStatement:
+		if stmt.Rest != nil {
+			return append(stmt.Names[:len(stmt.Names):len(stmt.Names)], stmt.Rest)
+		}
+		return stmt.Names
+	default:
+		return nil
+	}
+}
+
 // Identifier represents an identifier node
 type Identifier struct {
 	Token token.Token
@@ -85,6 +209,8 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) String() string       { return i.Value }
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 
 // ReturnStatement represents return statement node
 type ReturnStatement struct {
@@ -95,6 +221,13 @@ type ReturnStatement struct {
 // TokenLiteral returns the 'ReturnStatement' node token literal
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -118,7 +251,14 @@ type ExpressionStatement struct {
 func (ex *ExpressionStatement) TokenLiteral() string {
 	return ex.Token.Literal
 }
-func (ex *ExpressionStatement) statementNode() {}
+func (ex *ExpressionStatement) statementNode()      {}
+func (ex *ExpressionStatement) Pos() token.Position { return ex.Token.Pos }
+func (ex *ExpressionStatement) End() token.Position {
+	if ex.Expression != nil {
+		return ex.Expression.End()
+	}
+	return ex.Token.End
+}
 func (ex *ExpressionStatement) String() string {
 	if ex.Expression != nil {
 		return ex.Expression.String()
@@ -127,6 +267,18 @@ func (ex *ExpressionStatement) String() string {
 	return ""
 }
 
+// FloatLiteral represents a floating point number node
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+
 // IntegerLiteral represents a integer node
 type IntegerLiteral struct {
 	Token token.Token
@@ -136,6 +288,8 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 
 // PrefixExpression represents a prefix expression e.g: -5 or !false
 type PrefixExpression struct {
@@ -146,6 +300,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Right.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -167,6 +323,8 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position  { return ie.Right.End() }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -179,6 +337,23 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression represents an assignment to a variable or an element of
+// an array or hash, either plain (x = 1) or compound (x += 1)
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // the *Identifier or *IndexExpression assigned to
+	Operator string      // "=", "+=", "-=", "*=" or "/="
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position  { return ae.Value.End() }
+func (ae *AssignExpression) String() string {
+	return ae.Target.String() + " " + ae.Operator + " " + ae.Value.String()
+}
+
 // Boolean represents a boolean literal value
 type Boolean struct {
 	Token token.Token
@@ -188,14 +363,19 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // the '{' token
 	Statements []Statement
+	Rbrace     token.Position // position of the closing '}'
 }
 
 func (bl *BlockStatement) statementNode()       {}
 func (bl *BlockStatement) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BlockStatement) Pos() token.Position  { return bl.Token.Pos }
+func (bl *BlockStatement) End() token.Position  { return after(bl.Rbrace) }
 func (bl *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -206,15 +386,36 @@ func (bl *BlockStatement) String() string {
 	return out.String()
 }
 
+// IfExpression represents an if expression. The `else if` branches of a
+// chain are kept in order in ElseIfs, the final `else` block in Alternative.
 type IfExpression struct {
 	Token       token.Token // The 'if' token
 	Condition   Expression
 	Consequence *BlockStatement
+	ElseIfs     []*ElseIf
 	Alternative *BlockStatement
 }
 
+// ElseIf is an `else if (condition) { consequence }` branch of an
+// IfExpression
+type ElseIf struct {
+	Token       token.Token // the 'if' token following 'else'
+	Condition   Expression
+	Consequence *BlockStatement
+}
+
 func (i *IfExpression) expressionNode()      {}
 func (i *IfExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *IfExpression) Pos() token.Position  { return i.Token.Pos }
+func (i *IfExpression) End() token.Position {
+	if i.Alternative != nil {
+		return i.Alternative.End()
+	}
+	if n := len(i.ElseIfs); n > 0 {
+		return i.ElseIfs[n-1].Consequence.End()
+	}
+	return i.Consequence.End()
+}
 func (i *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -223,6 +424,13 @@ func (i *IfExpression) String() string {
 	out.WriteString(" ")
 	out.WriteString(i.Consequence.String())
 
+	for _, e := range i.ElseIfs {
+		out.WriteString("else if")
+		out.WriteString(e.Condition.String())
+		out.WriteString(" ")
+		out.WriteString(e.Consequence.String())
+	}
+
 	if i.Alternative != nil {
 		out.WriteString("else ")
 		out.WriteString(i.Alternative.String())
@@ -231,22 +439,359 @@ func (i *IfExpression) String() string {
 	return out.String()
 }
 
+// MatchExpression represents a `match (subject) { pattern => body, ... }`
+// expression. The arms are tried in order and the first one whose pattern
+// matches the subject, and whose guard if any is truthy, is evaluated.
+type MatchExpression struct {
+	Token   token.Token // The 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Position // position of the closing '}'
+}
+
+// MatchArm is a single `pattern if guard => body` arm of a MatchExpression.
+// Guard is nil if the arm has none. Body is an expression, not a block.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression
+	Body    Expression
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) End() token.Position  { return after(me.Rbrace) }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		s := arm.Pattern.String()
+		if arm.Guard != nil {
+			s += " if " + arm.Guard.String()
+		}
+		arms = append(arms, s+" => "+arm.Body.String())
+	}
+
+	out.WriteString("match ")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// Pattern describes a pattern of a match arm
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// WildcardPattern is the `_` pattern, it matches any value
+type WildcardPattern struct {
+	Token token.Token // The '_' token
+}
+
+func (wp *WildcardPattern) patternNode()         {}
+func (wp *WildcardPattern) TokenLiteral() string { return wp.Token.Literal }
+func (wp *WildcardPattern) String() string       { return wp.Token.Literal }
+func (wp *WildcardPattern) Pos() token.Position  { return wp.Token.Pos }
+func (wp *WildcardPattern) End() token.Position  { return wp.Token.End }
+
+// BindingPattern matches any value and binds it to Name
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) patternNode()         {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Name.TokenLiteral() }
+func (bp *BindingPattern) String() string       { return bp.Name.String() }
+func (bp *BindingPattern) Pos() token.Position  { return bp.Name.Pos() }
+func (bp *BindingPattern) End() token.Position  { return bp.Name.End() }
+
+// LiteralPattern matches a value equal to an integer, float, string or
+// boolean literal. Value is the literal, or a negated number literal.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+func (lp *LiteralPattern) Pos() token.Position  { return lp.Value.Pos() }
+func (lp *LiteralPattern) End() token.Position  { return lp.Value.End() }
+
+// ArrayPattern matches an array with exactly as many elements as the
+// pattern, each of them matching the corresponding element pattern
+type ArrayPattern struct {
+	Token    token.Token // The [ token
+	Elements []Pattern
+	Rbracket token.Position // position of the closing ']'
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) End() token.Position  { return after(ap.Rbracket) }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern matches a hash containing all of Keys, the value of each key
+// matching the pattern at the same index in Values. Other keys are ignored.
+type HashPattern struct {
+	Token  token.Token // the '{' token
+	Keys   []Expression
+	Values []Pattern
+	Rbrace token.Position // position of the closing '}'
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) End() token.Position  { return after(hp.Rbrace) }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for i, key := range hp.Keys {
+		pairs = append(pairs, key.String()+":"+hp.Values[i].String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// WhileStatement represents a `while (cond) { ... }` loop
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement represents a C-style `for (init; cond; post) { ... }` loop.
+// Init, Condition and Post are optional.
+type ForStatement struct {
+	Token     token.Token // the 'for' token
+	Init      Statement
+	Condition Expression
+	Post      Statement
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(strings.TrimSuffix(fs.Post.String(), ";"))
+	}
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// ForInStatement represents a `for (x in iterable) { ... }` loop
+type ForInStatement struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fi *ForInStatement) statementNode()       {}
+func (fi *ForInStatement) TokenLiteral() string { return fi.Token.Literal }
+func (fi *ForInStatement) Pos() token.Position  { return fi.Token.Pos }
+func (fi *ForInStatement) End() token.Position  { return fi.Body.End() }
+func (fi *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fi.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fi.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fi.Body.String())
+
+	return out.String()
+}
+
+// BreakStatement represents a `break` out of the innermost loop
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// ContinueStatement represents a `continue` with the next iteration of the
+// innermost loop
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+// TryStatement represents a `try { } catch (e) { } finally { }` statement.
+// Either the catch or the finally block may be left out.
+type TryStatement struct {
+	Token   token.Token // the 'try' token
+	Body    *BlockStatement
+	Param   *Identifier // the name bound to the caught value, nil without a catch block
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (ts *TryStatement) statementNode()       {}
+func (ts *TryStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TryStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *TryStatement) End() token.Position {
+	if ts.Finally != nil {
+		return ts.Finally.End()
+	}
+	return ts.Catch.End()
+}
+func (ts *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(ts.Body.String())
+
+	if ts.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(ts.Param.Value)
+		out.WriteString(") ")
+		out.WriteString(ts.Catch.String())
+	}
+	if ts.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(ts.Finally.String())
+	}
+
+	return out.String()
+}
+
+// ThrowStatement represents a `throw value;` statement
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) End() token.Position  { return ts.Value.End() }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// TypeStatement represents a record type declaration,
+// `type Point { x, y, fn move(self, dx, dy) { ... } }`
+type TypeStatement struct {
+	Token   token.Token // the 'type' token
+	Name    *Identifier
+	Fields  []*Identifier
+	Methods []*Method
+	Rbrace  token.Position // position of the closing '}'
+}
+
+func (ts *TypeStatement) statementNode()       {}
+func (ts *TypeStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *TypeStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *TypeStatement) End() token.Position  { return after(ts.Rbrace) }
+func (ts *TypeStatement) String() string {
+	members := []string{}
+	for _, f := range ts.Fields {
+		members = append(members, f.String())
+	}
+	for _, m := range ts.Methods {
+		members = append(members, m.String())
+	}
+
+	return fmt.Sprintf("%s %s { %s }", ts.TokenLiteral(), ts.Name.Value, strings.Join(members, ", "))
+}
+
+// Method is a method of a record type. Its first parameter is bound to the
+// record the method is called on.
+type Method struct {
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (m *Method) String() string {
+	return m.Function.TokenLiteral() + " " + m.Name.Value +
+		strings.TrimPrefix(m.Function.String(), m.Function.TokenLiteral())
+}
+
 // FunctionLiteral represents a function expression
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
-	Body       *BlockStatement
-	Name       string
+	// Defaults holds the default values of the trailing optional
+	// parameters, Defaults[i] belongs to Parameters[len(Parameters)-len(Defaults)+i]
+	Defaults []Expression
+	// Rest is the `...rest` parameter collecting the extra arguments, if any
+	Rest *Identifier
+	Body *BlockStatement
+	Name string
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	required := len(fl.Parameters) - len(fl.Defaults)
+	for i, p := range fl.Parameters {
+		if i < required {
+			params = append(params, p.String())
+		} else {
+			params = append(params, p.String()+" = "+fl.Defaults[i-required].String())
+		}
+	}
+	if fl.Rest != nil {
+		params = append(params, "..."+fl.Rest.String())
 	}
 	out.WriteString(fl.TokenLiteral())
 	if fl.Name != "" {
@@ -262,13 +807,16 @@ func (fl *FunctionLiteral) String() string {
 }
 
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // The ( token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Position // position of the closing ')'
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position  { return after(ce.Rparen) }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -292,14 +840,47 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+
+// InterpolatedString represents a string literal containing ${...}
+// expressions. Parts alternates between *StringLiteral and the interpolated
+// expressions, starting and ending with a *StringLiteral.
+type InterpolatedString struct {
+	Token token.Token // The first INTERPOLATION token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position {
+	return is.Parts[len(is.Parts)-1].End()
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for i, part := range is.Parts {
+		if i%2 == 0 {
+			out.WriteString(part.String())
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
 
 type ArrayLiteral struct {
 	Token    token.Token // The [ token
 	Elements []Expression
+	Rbracket token.Position // position of the closing ']'
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return after(al.Rbracket) }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -316,13 +897,16 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position // position of the closing ']'
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return after(ie.Rbracket) }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -335,13 +919,63 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression represents a `left[low:high]` expression. Low and High
+// are nil when left out.
+type SliceExpression struct {
+	Token    token.Token // The [ token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Position // position of the closing ']'
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position  { return after(se.Rbracket) }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// SelectorExpression represents a `left.name` field access or method lookup
+type SelectorExpression struct {
+	Token token.Token // The . token
+	Left  Expression
+	Name  *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SelectorExpression) End() token.Position  { return se.Name.End() }
+func (se *SelectorExpression) String() string {
+	return "(" + se.Left.String() + "." + se.Name.Value + ")"
+}
+
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Position // position of the closing '}'
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return after(hl.Rbrace) }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -365,6 +999,8 @@ type MacroLiteral struct {
 
 func (ml *MacroLiteral) expressionNode()      {}
 func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
+func (ml *MacroLiteral) End() token.Position  { return ml.Body.End() }
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
 
@@ -381,3 +1017,13 @@ func (ml *MacroLiteral) String() string {
 
 	return out.String()
 }
+
+// after returns the position following a single-character delimiter at p.
+func after(p token.Position) token.Position {
+	if !p.IsValid() {
+		return p
+	}
+	p.Offset++
+	p.Column++
+	return p
+}
@@ -15,17 +15,42 @@ func Modify(node Node, modifier ModifierFunc) Node {
 	case *InfixExpression:
 		node.Left = Modify(node.Left, modifier).(Expression)
 		node.Right = Modify(node.Right, modifier).(Expression)
+	case *AssignExpression:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 	case *IndexExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *SliceExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		if node.Low != nil {
+			node.Low, _ = Modify(node.Low, modifier).(Expression)
+		}
+		if node.High != nil {
+			node.High, _ = Modify(node.High, modifier).(Expression)
+		}
+	case *SelectorExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
 	case *IfExpression:
 		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		for _, e := range node.ElseIfs {
+			e.Condition, _ = Modify(e.Condition, modifier).(Expression)
+			e.Consequence, _ = Modify(e.Consequence, modifier).(*BlockStatement)
+		}
 		if node.Alternative != nil {
 			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
 		}
+	case *MatchExpression:
+		node.Subject, _ = Modify(node.Subject, modifier).(Expression)
+		for _, arm := range node.Arms {
+			if arm.Guard != nil {
+				arm.Guard, _ = Modify(arm.Guard, modifier).(Expression)
+			}
+			arm.Body, _ = Modify(arm.Body, modifier).(Expression)
+		}
 	case *BlockStatement:
 		for i := range node.Statements {
 			node.Statements[i], _ = Modify(node.Statements[i], modifier).(Statement)
@@ -34,11 +59,53 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
 	case *LetStatement:
 		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *DestructuringLetStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ExportStatement:
+		node.Statement, _ = Modify(node.Statement, modifier).(Statement)
+	case *TryStatement:
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			node.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
+	case *TypeStatement:
+		for _, m := range node.Methods {
+			m.Function, _ = Modify(m.Function, modifier).(*FunctionLiteral)
+		}
+	case *ThrowStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForStatement:
+		if node.Init != nil {
+			node.Init, _ = Modify(node.Init, modifier).(Statement)
+		}
+		if node.Condition != nil {
+			node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		}
+		if node.Post != nil {
+			node.Post, _ = Modify(node.Post, modifier).(Statement)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForInStatement:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 	case *FunctionLiteral:
 		for i := range node.Parameters {
 			node.Parameters[i], _ = Modify(node.Parameters[i], modifier).(*Identifier)
 		}
+		for i := range node.Defaults {
+			node.Defaults[i], _ = Modify(node.Defaults[i], modifier).(Expression)
+		}
 		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *InterpolatedString:
+		for i := range node.Parts {
+			node.Parts[i], _ = Modify(node.Parts[i], modifier).(Expression)
+		}
 	case *ArrayLiteral:
 		for i := range node.Elements {
 			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
@@ -39,6 +39,34 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpInterpolate
+	OpGetIter
+	OpIterNext
+	OpSetFree
+	OpCaptureLocal
+	OpCaptureFree
+	OpSetIndex
+	OpDup
+	OpMod
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpGreaterThanOrEqual
+	OpMatchArray
+	OpMatchHash
+	OpMatchKey
+	OpDestructureArray
+	OpDestructureHash
+	OpSlice
+	OpModule
+	OpTry
+	OpEndTry
+	OpThrow
+	OpRecordType
+	OpStackDepth
+	OpResetStack
 )
 
 // OperandWidth is the number of bytes an operand takes up
@@ -105,36 +133,64 @@ type Definition struct {
 }
 
 var definitions = map[Opcode]*Definition{
-	OpConstant:       {Name: "OpConstant", OperandWidths: []uint{OperandWidth2}},
-	OpPop:            {Name: "OpPop"},
-	OpAdd:            {Name: "OpAdd"},
-	OpSub:            {Name: "OpSub"},
-	OpMul:            {Name: "OpMul"},
-	OpDiv:            {Name: "OpDiv"},
-	OpTrue:           {Name: "OpTrue"},
-	OpFalse:          {Name: "OpFalse"},
-	OpEqual:          {Name: "OpEqual"},
-	OpNotEqual:       {Name: "OpNotEqual"},
-	OpGreaterThan:    {Name: "OpGreaterThan"},
-	OpBang:           {Name: "OpBang"},
-	OpMinus:          {Name: "OpMinus"},
-	OpJump:           {Name: "OpJump", OperandWidths: []uint{OperandWidth2}},
-	OpJumpNotTruthy:  {Name: "OpJumpNotTruthy", OperandWidths: []uint{OperandWidth2}},
-	OpNull:           {Name: "OpNull"},
-	OpGetGlobal:      {Name: "OpGetGlobal", OperandWidths: []uint{OperandWidth2}},
-	OpSetGlobal:      {Name: "OpSetGlobal", OperandWidths: []uint{OperandWidth2}},
-	OpArray:          {Name: "OpArray", OperandWidths: []uint{OperandWidth2}},
-	OpHash:           {Name: "OpHash", OperandWidths: []uint{OperandWidth2}},
-	OpIndex:          {Name: "OpIndex"},
-	OpCall:           {Name: "OpCall", OperandWidths: []uint{OperandWidth1}},
-	OpReturnValue:    {Name: "OpReturnValue"},
-	OpReturn:         {Name: "OpReturn"},
-	OpGetLocal:       {Name: "OpGetLocal", OperandWidths: []uint{OperandWidth1}},
-	OpSetLocal:       {Name: "OpSetLocal", OperandWidths: []uint{OperandWidth1}},
-	OpGetBuiltin:     {Name: "OpGetBuiltin", OperandWidths: []uint{OperandWidth1}},
-	OpClosure:        {Name: "OpClosure", OperandWidths: []uint{OperandWidth2, OperandWidth1}},
-	OpGetFree:        {"OpGetFree", []uint{OperandWidth1}},
-	OpCurrentClosure: {Name: "OpCurrentClosure"},
+	OpConstant:           {Name: "OpConstant", OperandWidths: []uint{OperandWidth2}},
+	OpPop:                {Name: "OpPop"},
+	OpAdd:                {Name: "OpAdd"},
+	OpSub:                {Name: "OpSub"},
+	OpMul:                {Name: "OpMul"},
+	OpDiv:                {Name: "OpDiv"},
+	OpTrue:               {Name: "OpTrue"},
+	OpFalse:              {Name: "OpFalse"},
+	OpEqual:              {Name: "OpEqual"},
+	OpNotEqual:           {Name: "OpNotEqual"},
+	OpGreaterThan:        {Name: "OpGreaterThan"},
+	OpBang:               {Name: "OpBang"},
+	OpMinus:              {Name: "OpMinus"},
+	OpJump:               {Name: "OpJump", OperandWidths: []uint{OperandWidth2}},
+	OpJumpNotTruthy:      {Name: "OpJumpNotTruthy", OperandWidths: []uint{OperandWidth2}},
+	OpNull:               {Name: "OpNull"},
+	OpGetGlobal:          {Name: "OpGetGlobal", OperandWidths: []uint{OperandWidth2}},
+	OpSetGlobal:          {Name: "OpSetGlobal", OperandWidths: []uint{OperandWidth2}},
+	OpArray:              {Name: "OpArray", OperandWidths: []uint{OperandWidth2}},
+	OpHash:               {Name: "OpHash", OperandWidths: []uint{OperandWidth2}},
+	OpIndex:              {Name: "OpIndex"},
+	OpCall:               {Name: "OpCall", OperandWidths: []uint{OperandWidth1}},
+	OpReturnValue:        {Name: "OpReturnValue"},
+	OpReturn:             {Name: "OpReturn"},
+	OpGetLocal:           {Name: "OpGetLocal", OperandWidths: []uint{OperandWidth1}},
+	OpSetLocal:           {Name: "OpSetLocal", OperandWidths: []uint{OperandWidth1}},
+	OpGetBuiltin:         {Name: "OpGetBuiltin", OperandWidths: []uint{OperandWidth1}},
+	OpClosure:            {Name: "OpClosure", OperandWidths: []uint{OperandWidth2, OperandWidth1}},
+	OpGetFree:            {"OpGetFree", []uint{OperandWidth1}},
+	OpCurrentClosure:     {Name: "OpCurrentClosure"},
+	OpInterpolate:        {Name: "OpInterpolate", OperandWidths: []uint{OperandWidth2}},
+	OpGetIter:            {Name: "OpGetIter"},
+	OpIterNext:           {Name: "OpIterNext", OperandWidths: []uint{OperandWidth2}},
+	OpSetFree:            {Name: "OpSetFree", OperandWidths: []uint{OperandWidth1}},
+	OpCaptureLocal:       {Name: "OpCaptureLocal", OperandWidths: []uint{OperandWidth1}},
+	OpCaptureFree:        {Name: "OpCaptureFree", OperandWidths: []uint{OperandWidth1}},
+	OpSetIndex:           {Name: "OpSetIndex"},
+	OpDup:                {Name: "OpDup", OperandWidths: []uint{OperandWidth1}},
+	OpMod:                {Name: "OpMod"},
+	OpBitAnd:             {Name: "OpBitAnd"},
+	OpBitOr:              {Name: "OpBitOr"},
+	OpBitXor:             {Name: "OpBitXor"},
+	OpShiftLeft:          {Name: "OpShiftLeft"},
+	OpShiftRight:         {Name: "OpShiftRight"},
+	OpGreaterThanOrEqual: {Name: "OpGreaterThanOrEqual"},
+	OpMatchArray:         {Name: "OpMatchArray", OperandWidths: []uint{OperandWidth2}},
+	OpMatchHash:          {Name: "OpMatchHash"},
+	OpMatchKey:           {Name: "OpMatchKey"},
+	OpDestructureArray:   {Name: "OpDestructureArray", OperandWidths: []uint{OperandWidth2, OperandWidth1}},
+	OpDestructureHash:    {Name: "OpDestructureHash", OperandWidths: []uint{OperandWidth2}},
+	OpSlice:              {Name: "OpSlice"},
+	OpModule:             {Name: "OpModule", OperandWidths: []uint{OperandWidth2}},
+	OpTry:                {Name: "OpTry", OperandWidths: []uint{OperandWidth2}},
+	OpEndTry:             {Name: "OpEndTry"},
+	OpThrow:              {Name: "OpThrow"},
+	OpRecordType:         {Name: "OpRecordType", OperandWidths: []uint{OperandWidth2, OperandWidth2}},
+	OpStackDepth:         {Name: "OpStackDepth"},
+	OpResetStack:         {Name: "OpResetStack"},
 }
 
 func Lookup(op Opcode) (*Definition, error) {
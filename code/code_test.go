@@ -27,6 +27,8 @@ func TestMake(t *testing.T) {
 		{OpSetLocal, []int{255}, []byte{byte(OpSetLocal), 255}},
 		{OpSetLocal, []int{255}, []byte{byte(OpSetLocal), 255}},
 		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpInterpolate, []int{3}, []byte{byte(OpInterpolate), 0, 3}},
+		{OpRecordType, []int{2, 258}, []byte{byte(OpRecordType), 0, 2, 1, 2}},
 	}
 
 	for _, tC := range testCases {
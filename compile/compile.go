@@ -6,6 +6,7 @@ import (
 
 	"github.com/dikaeinstein/monkey/ast"
 	"github.com/dikaeinstein/monkey/code"
+	"github.com/dikaeinstein/monkey/macro"
 	"github.com/dikaeinstein/monkey/object"
 	"github.com/dikaeinstein/monkey/token"
 )
@@ -20,6 +21,49 @@ type CompilationScope struct {
 
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+
+	// loops holds the loops enclosing the code being compiled, innermost last
+	loops []*loopJumps
+	// matches is the number of match expressions enclosing the code being
+	// compiled
+	matches int
+	// tries holds the exception handlers installed around the code being
+	// compiled, innermost last
+	tries []*tryBlock
+	// finallies is the number of finally blocks compiled so far, used to
+	// name the hidden symbols holding the exceptions they rethrow
+	finallies int
+	// blocks holds the block scopes enclosing the code being compiled,
+	// innermost last
+	blocks []blockScope
+}
+
+// blockScope maps each name defined in a block with a scope of its own, such
+// as a match arm, to the symbol of the same name it hides outside the block
+type blockScope map[string]hiddenSymbol
+
+type hiddenSymbol struct {
+	sym Symbol
+	ok  bool // false if the name was not defined outside the block
+}
+
+// loopJumps records the jumps emitted for the break and continue
+// statements of a loop, which are patched once the loop has been compiled
+type loopJumps struct {
+	breaks    []int
+	continues []int
+	// tries is the number of exception handlers installed when the loop
+	// was entered
+	tries int
+	// depth holds the depth of the stack when the loop was entered
+	depth Symbol
+}
+
+// tryBlock is an exception handler installed by a try statement, along with
+// the finally block to run when leaving it, if any
+type tryBlock struct {
+	finally *ast.BlockStatement
+	value   Symbol // holds the value of the block while finally runs
 }
 
 // Compiler wraps the bytecode instructions and constants pool.
@@ -29,6 +73,16 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	// modules maps the path of each module compiled so far to the hidden
+	// global holding its value
+	modules map[string]Symbol
+	// importing holds the paths of the modules being compiled, in import
+	// order, to detect import cycles
+	importing []string
+	// evalMacro evaluates the macros of the modules imported. Importing a
+	// module defining macros is an error if it is nil.
+	evalMacro macro.Evaluator
 }
 
 // New returns a new instance of the Compiler
@@ -44,6 +98,7 @@ func New(symbolTable *SymbolTable, constants []object.Object) *Compiler {
 		symbolTable: symbolTable,
 		scopes:      []CompilationScope{mainScope},
 		scopeIndex:  0,
+		modules:     make(map[string]Symbol),
 	}
 }
 
@@ -59,6 +114,12 @@ func NewCompilerWithBuiltins(constants []object.Object) *Compiler {
 	return New(symbolTable, constants)
 }
 
+// SetMacroEvaluator sets the evaluator used to expand the macros of the
+// modules imported by the compiled program
+func (c *Compiler) SetMacroEvaluator(eval macro.Evaluator) {
+	c.evalMacro = eval
+}
+
 // Compile compiles the AST into Bytecode. It fills the compiler instructions
 // and constant pool with compiled bytecode instructions and evaluated
 // constants.
@@ -83,6 +144,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+	case *ast.AssignExpression:
+		err := c.compileAssignExpression(node)
+		if err != nil {
+			return err
+		}
 	case *ast.PrefixExpression:
 		err := c.compilePrefixExpression(node)
 		if err != nil {
@@ -91,6 +157,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.IntegerLiteral:
 		integer := object.Integer(node.Value)
 		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := object.Float(node.Value)
+		c.emit(code.OpConstant, c.addConstant(float))
 	case *ast.Boolean:
 		if node.Value {
 			c.emit(code.OpTrue)
@@ -109,10 +178,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+	case *ast.MatchExpression:
+		err := c.compileMatchExpression(node)
+		if err != nil {
+			return err
+		}
 	case *ast.Identifier:
 		sym, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			return fmt.Errorf("undefined variable: %s", node.Value)
+			return fmt.Errorf("%s: undefined variable: %s", node.Pos(), node.Value)
 		}
 		c.loadSymbol(sym)
 	case *ast.LetStatement:
@@ -120,6 +194,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+	case *ast.DestructuringLetStatement:
+		err := c.compileDestructuringLetStatement(node)
+		if err != nil {
+			return err
+		}
+	case *ast.TypeStatement:
+		err := c.compileTypeStatement(node)
+		if err != nil {
+			return err
+		}
 	case *ast.StringLiteral:
 		str := object.String(node.Value)
 		c.emit(code.OpConstant, c.addConstant(str))
@@ -131,6 +215,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 		c.emit(code.OpArray, len(node.Elements))
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			err := c.Compile(part)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
 	case *ast.HashLiteral:
 		err := c.compileHashLiteral(node)
 		if err != nil {
@@ -141,23 +233,80 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+	case *ast.SliceExpression:
+		err := c.compileSliceExpression(node)
+		if err != nil {
+			return err
+		}
+	case *ast.SelectorExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpConstant, c.addConstant(object.String(node.Name.Value)))
+		c.emit(code.OpIndex)
 	case *ast.FunctionLiteral:
 		err := c.compileFunctionLiteral(node)
 		if err != nil {
 			return err
 		}
+	case *ast.ImportStatement:
+		err := c.compileImportStatement(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ExportStatement:
+		err := c.Compile(node.Statement)
+		if err != nil {
+			return err
+		}
 	case *ast.ReturnStatement:
 		err := c.Compile(node.ReturnValue)
 		if err != nil {
 			return err
 		}
 
+		err = c.leaveTries(0)
+		if err != nil {
+			return err
+		}
 		c.emit(code.OpReturnValue)
+	case *ast.TryStatement:
+		err := c.compileTryStatement(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ThrowStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
 	case *ast.CallExpression:
 		err := c.compileCallExpression(node)
 		if err != nil {
 			return err
 		}
+	case *ast.WhileStatement:
+		err := c.compileWhileStatement(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ForStatement:
+		err := c.compileForStatement(node)
+		if err != nil {
+			return err
+		}
+	case *ast.ForInStatement:
+		err := c.compileForInStatement(node)
+		if err != nil {
+			return err
+		}
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		err := c.compileLoopJump(node.(ast.Statement))
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -177,9 +326,13 @@ func (c *Compiler) Bytecode() *Bytecode {
 }
 
 func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	if node.Operator == string(token.AND) || node.Operator == string(token.OR) {
+		return c.compileLogicalExpression(node)
+	}
+
 	// invert the operands for lessThan operator so the compiler can use
 	// the greaterThan operator
-	if node.Operator == string(token.LT) {
+	if node.Operator == string(token.LT) || node.Operator == string(token.LtEQ) {
 		err := c.Compile(node.Right)
 		if err != nil {
 			return err
@@ -189,7 +342,11 @@ func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
 			return err
 		}
 
-		c.emit(code.OpGreaterThan)
+		if node.Operator == string(token.LT) {
+			c.emit(code.OpGreaterThan)
+		} else {
+			c.emit(code.OpGreaterThanOrEqual)
+		}
 		return nil
 	}
 
@@ -211,19 +368,188 @@ func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
 		c.emit(code.OpMul)
 	case string(token.SLASH):
 		c.emit(code.OpDiv)
+	case string(token.PERCENT):
+		c.emit(code.OpMod)
+	case string(token.AMPERSAND):
+		c.emit(code.OpBitAnd)
+	case string(token.PIPE):
+		c.emit(code.OpBitOr)
+	case string(token.CARET):
+		c.emit(code.OpBitXor)
+	case string(token.ShiftLeft):
+		c.emit(code.OpShiftLeft)
+	case string(token.ShiftRight):
+		c.emit(code.OpShiftRight)
 	case string(token.EQ):
 		c.emit(code.OpEqual)
 	case string(token.NotEQ):
 		c.emit(code.OpNotEqual)
 	case string(token.GT):
 		c.emit(code.OpGreaterThan)
+	case string(token.GtEQ):
+		c.emit(code.OpGreaterThanOrEqual)
+	default:
+		return fmt.Errorf("%s: unknown operator %s", node.Token.Pos, node.Operator)
+	}
+
+	return nil
+}
+
+// compileLogicalExpression compiles && and || to jumps that skip the right
+// operand once the left one decides the result. Both produce a boolean:
+//
+//	a && b                        a || b
+//	  <a>                           <a>
+//	  OpJumpNotTruthy false         OpJumpNotTruthy right
+//	  <b>                           OpTrue
+//	  OpJumpNotTruthy false         OpJump end
+//	  OpTrue                      right:
+//	  OpJump end                    <b>
+//	false:                          OpJumpNotTruthy false
+//	  OpFalse                       OpTrue
+//	end:                            OpJump end
+//	                              false:
+//	                                OpFalse
+//	                              end:
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	const bogus = 9999
+	leftFalsePos := c.emit(code.OpJumpNotTruthy, bogus)
+
+	var leftTrueJumpPos int
+	if node.Operator == string(token.OR) {
+		c.emit(code.OpTrue)
+		leftTrueJumpPos = c.emit(code.OpJump, bogus)
+		c.changeOperands(leftFalsePos, len(c.currentInstructions()))
+	}
+
+	err = c.Compile(node.Right)
+	if err != nil {
+		return err
+	}
+
+	rightFalsePos := c.emit(code.OpJumpNotTruthy, bogus)
+	c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, bogus)
+
+	falsePos := c.emit(code.OpFalse)
+	if node.Operator == string(token.AND) {
+		c.changeOperands(leftFalsePos, falsePos)
+	}
+	c.changeOperands(rightFalsePos, falsePos)
+
+	endPos := len(c.currentInstructions())
+	c.changeOperands(jumpPos, endPos)
+	if node.Operator == string(token.OR) {
+		c.changeOperands(leftTrueJumpPos, endPos)
+	}
+
+	return nil
+}
+
+// compileAssignExpression stores the new value in the variable or element
+// and leaves it on the stack as the value of the expression
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		return c.compileVariableAssignment(node, target)
+	case *ast.IndexExpression:
+		return c.compileIndexAssignment(node, target)
+	case *ast.SelectorExpression:
+		return c.compileSelectorAssignment(node, target)
 	default:
-		return fmt.Errorf("unknown operator %s", node.Operator)
+		return fmt.Errorf("%s: cannot assign to %s", node.Target.Pos(), node.Target)
+	}
+}
+
+func (c *Compiler) compileVariableAssignment(node *ast.AssignExpression, ident *ast.Identifier) error {
+	sym, ok := c.symbolTable.Resolve(ident.Value)
+	if !ok {
+		return fmt.Errorf("%s: undefined variable: %s", ident.Pos(), ident.Value)
+	}
+	if sym.Scope != GlobalScope && sym.Scope != LocalScope && sym.Scope != FreeScope {
+		return fmt.Errorf("%s: cannot assign to %s", ident.Pos(), ident.Value)
+	}
+
+	if node.Operator != string(token.ASSIGN) {
+		c.loadSymbol(sym)
+	}
+
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+	c.emitAssignOperator(node.Operator)
+
+	c.storeSymbol(sym)
+	c.loadSymbol(sym)
+
+	return nil
+}
+
+// compileIndexAssignment leaves the array or hash, the index and the new
+// value on the stack for OpSetIndex
+func (c *Compiler) compileIndexAssignment(node *ast.AssignExpression, target *ast.IndexExpression) error {
+	err := c.Compile(target.Left)
+	if err != nil {
+		return err
+	}
+	err = c.Compile(target.Index)
+	if err != nil {
+		return err
+	}
+
+	return c.compileSetIndex(node)
+}
+
+// compileSelectorAssignment assigns to `left.name` as to `left["name"]`
+func (c *Compiler) compileSelectorAssignment(node *ast.AssignExpression, target *ast.SelectorExpression) error {
+	err := c.Compile(target.Left)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpConstant, c.addConstant(object.String(target.Name.Value)))
+
+	return c.compileSetIndex(node)
+}
+
+// compileSetIndex pushes the new value of the element whose container and
+// index are on the stack, then emits OpSetIndex
+func (c *Compiler) compileSetIndex(node *ast.AssignExpression) error {
+	if node.Operator != string(token.ASSIGN) {
+		c.emit(code.OpDup, 2)
+		c.emit(code.OpIndex)
+	}
+
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
 	}
+	c.emitAssignOperator(node.Operator)
 
+	c.emit(code.OpSetIndex)
 	return nil
 }
 
+// emitAssignOperator emits the arithmetic of a compound assignment such as
+// +=. A plain assignment emits nothing.
+func (c *Compiler) emitAssignOperator(operator string) {
+	switch operator {
+	case string(token.PlusAssign):
+		c.emit(code.OpAdd)
+	case string(token.MinusAssign):
+		c.emit(code.OpSub)
+	case string(token.AsteriskAssign):
+		c.emit(code.OpMul)
+	case string(token.SlashAssign):
+		c.emit(code.OpDiv)
+	}
+}
+
 func (c *Compiler) compilePrefixExpression(node *ast.PrefixExpression) error {
 	err := c.Compile(node.Right)
 	if err != nil {
@@ -236,58 +562,496 @@ func (c *Compiler) compilePrefixExpression(node *ast.PrefixExpression) error {
 	case string(token.MINUS):
 		c.emit(code.OpMinus)
 	default:
-		return fmt.Errorf("unknown operator %s", node.Operator)
+		return fmt.Errorf("%s: unknown operator %s", node.Token.Pos, node.Operator)
 	}
 
 	return nil
 }
 
+// compileIfExpression compiles each branch of an if/else-if chain as a
+// condition jumping over its consequence to the next branch, and a
+// consequence jumping to the end of the chain once it has produced a value
 func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	conditions := []ast.Expression{node.Condition}
+	consequences := []*ast.BlockStatement{node.Consequence}
+	for _, e := range node.ElseIfs {
+		conditions = append(conditions, e.Condition)
+		consequences = append(consequences, e.Consequence)
+	}
+
+	const bogus = 9999
+	jumpPositions := []int{}
+
+	for i, condition := range conditions {
+		err := c.Compile(condition)
+		if err != nil {
+			return err
+		}
+
+		// Emit an `OpJumpNotTruthy` with a bogus value
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, bogus)
+		err = c.Compile(consequences[i])
+		if err != nil {
+			return err
+		}
+
+		c.blockValue()
+
+		// Emit an `OpJump` with a bogus value
+		jumpPositions = append(jumpPositions, c.emit(code.OpJump, bogus))
+
+		afterConsequencePos := len(c.currentInstructions())
+		c.changeOperands(jumpNotTruthyPos, afterConsequencePos)
+	}
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		err := c.Compile(node.Alternative)
+		if err != nil {
+			return err
+		}
+
+		c.blockValue()
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	for _, pos := range jumpPositions {
+		c.changeOperands(pos, afterAlternativePos)
+	}
+
+	return nil
+}
+
+// compileMatchExpression stores the subject in a hidden variable, then
+// compiles each arm as a sequence of tests on it jumping to the next arm as
+// soon as one fails. An arm whose tests all pass evaluates its body and
+// jumps to the end; if no arm matches the expression evaluates to null.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	err := c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+
+	subject := c.define(fmt.Sprintf("$match%d", c.scopes[c.scopeIndex].matches))
+	c.storeSymbol(subject)
+
+	c.scopes[c.scopeIndex].matches++
+	defer func() { c.scopes[c.scopeIndex].matches-- }()
+
+	const bogus = 9999
+	endJumps := []int{}
+
+	for _, arm := range node.Arms {
+		// the pattern binds fresh variables, so an arm that fails half way
+		// leaves the variables outside the match untouched
+		c.enterBlock()
+		failJumps, err := c.compilePattern(arm.Pattern, func() error {
+			c.loadSymbol(subject)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if arm.Guard != nil {
+			err := c.Compile(arm.Guard)
+			if err != nil {
+				return err
+			}
+			failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, bogus))
+		}
+
+		err = c.Compile(arm.Body)
+		if err != nil {
+			return err
+		}
+		c.leaveBlock()
+		endJumps = append(endJumps, c.emit(code.OpJump, bogus))
+
+		nextArmPos := len(c.currentInstructions())
+		for _, pos := range failJumps {
+			c.changeOperands(pos, nextArmPos)
+		}
+	}
+
+	c.emit(code.OpNull)
+
+	endPos := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperands(pos, endPos)
+	}
+
+	return nil
+}
+
+// compilePattern emits the tests and bindings of pattern against the value
+// pushed by load. It returns the positions of the jumps taken when a test
+// fails, which the caller has to patch.
+func (c *Compiler) compilePattern(pattern ast.Pattern, load func() error) ([]int, error) {
+	const bogus = 9999
+	failJumps := []int{}
+
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		// matches anything
+	case *ast.BindingPattern:
+		err := load()
+		if err != nil {
+			return nil, err
+		}
+		c.storeSymbol(c.define(pattern.Name.Value))
+	case *ast.LiteralPattern:
+		err := load()
+		if err != nil {
+			return nil, err
+		}
+		err = c.Compile(pattern.Value)
+		if err != nil {
+			return nil, err
+		}
+		c.emit(code.OpEqual)
+		failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, bogus))
+	case *ast.ArrayPattern:
+		err := load()
+		if err != nil {
+			return nil, err
+		}
+		c.emit(code.OpMatchArray, len(pattern.Elements))
+		failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, bogus))
+
+		for i, el := range pattern.Elements {
+			index := i
+			jumps, err := c.compilePattern(el, func() error {
+				err := load()
+				if err != nil {
+					return err
+				}
+				c.emit(code.OpConstant, c.addConstant(object.Integer(index)))
+				c.emit(code.OpIndex)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			failJumps = append(failJumps, jumps...)
+		}
+	case *ast.HashPattern:
+		err := load()
+		if err != nil {
+			return nil, err
+		}
+		c.emit(code.OpMatchHash)
+		failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, bogus))
+
+		for i, k := range pattern.Keys {
+			key := k
+			loadValue := func() error {
+				err := load()
+				if err != nil {
+					return err
+				}
+				return c.Compile(key)
+			}
+
+			err := loadValue()
+			if err != nil {
+				return nil, err
+			}
+			c.emit(code.OpMatchKey)
+			failJumps = append(failJumps, c.emit(code.OpJumpNotTruthy, bogus))
+
+			jumps, err := c.compilePattern(pattern.Values[i], func() error {
+				err := loadValue()
+				if err != nil {
+					return err
+				}
+				c.emit(code.OpIndex)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			failJumps = append(failJumps, jumps...)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown pattern %s", pattern.Pos(), pattern)
+	}
+
+	return failJumps, nil
+}
+
+// blockValue leaves the value of the block just compiled on the stack: the
+// value of its last expression statement, or null if it ends with any
+// other statement
+func (c *Compiler) blockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	c.enterLoop()
+	startPos := len(c.currentInstructions())
+
 	err := c.Compile(node.Condition)
 	if err != nil {
 		return err
 	}
 
 	const bogus = 9999
-	// Emit an `OpJumpNotTruthy` with a bogus value
 	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, bogus)
-	err = c.Compile(node.Consequence)
+
+	err = c.Compile(node.Body)
 	if err != nil {
 		return err
 	}
+	c.emit(code.OpJump, startPos)
 
-	if c.lastInstructionIs(code.OpPop) {
-		c.removeLastPop()
+	c.changeOperands(jumpNotTruthyPos, len(c.currentInstructions()))
+	c.leaveLoop(startPos)
+
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if node.Init != nil {
+		err := c.Compile(node.Init)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Emit an `OpJump` with a bogus value
-	jumpPos := c.emit(code.OpJump, bogus)
+	c.enterLoop()
+	startPos := len(c.currentInstructions())
 
-	afterConsequencePos := len(c.currentInstructions())
-	c.changeOperands(jumpNotTruthyPos, afterConsequencePos)
+	const bogus = 9999
+	jumpNotTruthyPos := -1
+	if node.Condition != nil {
+		err := c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruthyPos = c.emit(code.OpJumpNotTruthy, bogus)
+	}
 
-	if node.Alternative == nil {
-		c.emit(code.OpNull)
+	err := c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+
+	postPos := len(c.currentInstructions())
+	if node.Post != nil {
+		err := c.Compile(node.Post)
+		if err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpJump, startPos)
+
+	if jumpNotTruthyPos >= 0 {
+		c.changeOperands(jumpNotTruthyPos, len(c.currentInstructions()))
+	}
+	c.leaveLoop(postPos)
+
+	return nil
+}
+
+// compileForInStatement keeps the iterator in a hidden variable and
+// assigns each element to the loop variable until OpIterNext jumps out. The
+// variable is cleared when the loop is left.
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	err := c.Compile(node.Iterable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpGetIter)
+
+	iter := c.define(fmt.Sprintf("$iter%d", len(c.scopes[c.scopeIndex].loops)))
+	c.storeSymbol(iter)
+
+	c.enterLoop()
+	startPos := len(c.currentInstructions())
+	c.loadSymbol(iter)
+
+	const bogus = 9999
+	iterNextPos := c.emit(code.OpIterNext, bogus)
+	c.storeSymbol(c.define(node.Variable.Value))
+
+	err = c.Compile(node.Body)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpJump, startPos)
+
+	c.changeOperands(iterNextPos, len(c.currentInstructions()))
+	c.leaveLoop(startPos)
+
+	// release the iterator, which holds on to the iterated value
+	c.emit(code.OpNull)
+	c.storeSymbol(iter)
+
+	return nil
+}
+
+// compileLoopJump emits the jump for a break or continue statement. Its
+// target is filled in by leaveLoop.
+func (c *Compiler) compileLoopJump(node ast.Statement) error {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return fmt.Errorf("%s: %s outside loop", node.Pos(), node.TokenLiteral())
+	}
+	loop := loops[len(loops)-1]
+
+	err := c.leaveTries(loop.tries)
+	if err != nil {
+		return err
+	}
+
+	// the jump can leave an expression half evaluated, e.g.
+	// `[1, if (x) { break }]`, so the stack is reset first
+	c.loadSymbol(loop.depth)
+	c.emit(code.OpResetStack)
+
+	const bogus = 9999
+	pos := c.emit(code.OpJump, bogus)
+	if _, ok := node.(*ast.BreakStatement); ok {
+		loop.breaks = append(loop.breaks, pos)
 	} else {
-		afterConsequencePos := len(c.currentInstructions())
-		c.changeOperands(jumpNotTruthyPos, afterConsequencePos)
+		loop.continues = append(loop.continues, pos)
+	}
 
-		err = c.Compile(node.Alternative)
+	return nil
+}
+
+// compileTryStatement compiles the try block under an exception handler
+// which jumps to the catch block, passing it the caught value. With a
+// finally block, the catch block runs under a second handler, and a copy of
+// the finally block follows each block and every jump out of them. The
+// handlers with no catch block to go to run the finally block and rethrow.
+// The statement leaves the value of the try or catch block on the stack.
+func (c *Compiler) compileTryStatement(node *ast.TryStatement) error {
+	const bogus = 9999
+	var ends []int
+
+	try := &tryBlock{finally: node.Finally}
+	var exception Symbol
+	if node.Finally != nil {
+		scope := &c.scopes[c.scopeIndex]
+		try.value = c.define(fmt.Sprintf("$value%d", scope.finallies))
+		exception = c.define(fmt.Sprintf("$exception%d", scope.finallies))
+		scope.finallies++
+	}
+
+	tryPos := c.emit(code.OpTry, bogus)
+	err := c.compileTryBlock(node.Body, try)
+	if err != nil {
+		return err
+	}
+	ends = append(ends, c.emit(code.OpJump, bogus))
+
+	rethrowPos := tryPos
+	if node.Catch != nil {
+		c.changeOperands(tryPos, len(c.currentInstructions()))
+
+		// the catch parameter is only visible in the catch block
+		c.enterBlock()
+		c.storeSymbol(c.define(node.Param.Value))
+
+		if node.Finally != nil {
+			rethrowPos = c.emit(code.OpTry, bogus)
+			err = c.compileTryBlock(node.Catch, try)
+		} else if err = c.Compile(node.Catch); err == nil {
+			c.blockValue()
+		}
 		if err != nil {
 			return err
 		}
+		c.leaveBlock()
+		ends = append(ends, c.emit(code.OpJump, bogus))
+	}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
+	if node.Finally != nil {
+		c.changeOperands(rethrowPos, len(c.currentInstructions()))
+
+		c.storeSymbol(exception)
+		err = c.Compile(node.Finally)
+		if err != nil {
+			return err
 		}
+		c.loadSymbol(exception)
+		c.emit(code.OpThrow)
 	}
 
-	afterAlternativePos := len(c.currentInstructions())
-	c.changeOperands(jumpPos, afterAlternativePos)
+	for _, pos := range ends {
+		c.changeOperands(pos, len(c.currentInstructions()))
+	}
+
+	if node.Finally != nil {
+		c.loadSymbol(try.value)
+	}
+	c.emit(code.OpPop)
 
 	return nil
 }
 
+// compileTryBlock compiles block under the exception handler of try,
+// installed just before it, which is removed at the end of the block,
+// followed by the finally block if there is one. The value of block is kept
+// in the hidden variable of try while the finally block runs, so that the
+// stack is the same as when a jump leaves the block.
+func (c *Compiler) compileTryBlock(block *ast.BlockStatement, try *tryBlock) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, try)
+
+	err := c.Compile(block)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+
+	if err != nil {
+		return err
+	}
+
+	c.blockValue()
+	if try.finally != nil {
+		c.storeSymbol(try.value)
+	}
+
+	return c.leaveTry(try)
+}
+
+// leaveTries removes the exception handlers installed after the first n,
+// innermost first, running their finally blocks. It is used to jump out of
+// them.
+func (c *Compiler) leaveTries(n int) error {
+	tries := c.scopes[c.scopeIndex].tries
+
+	for i := len(tries) - 1; i >= n; i-- {
+		// the finally block runs outside the handler it belongs to
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		err := c.leaveTry(tries[i])
+		if err != nil {
+			return err
+		}
+	}
+	c.scopes[c.scopeIndex].tries = tries
+
+	return nil
+}
+
+// leaveTry removes the exception handler of try and runs its finally block
+func (c *Compiler) leaveTry(try *tryBlock) error {
+	c.emit(code.OpEndTry)
+	if try.finally == nil {
+		return nil
+	}
+
+	return c.Compile(try.finally)
+}
+
 func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
 	c.enterScope()
 
@@ -295,8 +1059,29 @@ func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
 		c.symbolTable.DefineFunctionName(node.Name)
 	}
 
+	params := []Symbol{}
 	for _, p := range node.Parameters {
-		c.symbolTable.Define(p.Value)
+		params = append(params, c.symbolTable.Define(p.Value))
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	// each default value is stored in its parameter in turn, the caller
+	// enters at the first parameter it didn't pass
+	var entries []int
+	required := len(node.Parameters) - len(node.Defaults)
+	for i, d := range node.Defaults {
+		entries = append(entries, len(c.currentInstructions()))
+
+		err := c.Compile(d)
+		if err != nil {
+			return err
+		}
+		c.storeSymbol(params[required+i])
+	}
+	if len(node.Defaults) > 0 {
+		entries = append(entries, len(c.currentInstructions()))
 	}
 
 	err := c.Compile(node.Body)
@@ -317,13 +1102,16 @@ func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
 	instructions := c.leaveScope()
 
 	for _, s := range freeSymbols {
-		c.loadSymbol(s)
+		c.captureSymbol(s)
 	}
 
 	compiledFn := &object.CompiledFunction{
-		Instructions:  instructions,
-		NumLocals:     numLocals,
-		NumParameters: len(node.Parameters),
+		Instructions:   instructions,
+		NumLocals:      numLocals,
+		NumParameters:  len(node.Parameters),
+		NumDefaults:    len(node.Defaults),
+		DefaultEntries: entries,
+		Variadic:       node.Rest != nil,
 	}
 	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
 
@@ -372,17 +1160,70 @@ func (c *Compiler) compileCallExpression(node *ast.CallExpression) error {
 }
 
 func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
-	sym := c.symbolTable.Define(node.Name.Value)
+	sym := c.define(node.Name.Value)
 
 	err := c.Compile(node.Value)
 	if err != nil {
 		return err
 	}
 
-	if sym.Scope == GlobalScope {
-		c.emit(code.OpSetGlobal, sym.Index)
+	c.storeSymbol(sym)
+	return nil
+}
+
+// compileTypeStatement pushes the name of the type, the names of its fields
+// and a name and closure pair for each method, then builds the type
+func (c *Compiler) compileTypeStatement(node *ast.TypeStatement) error {
+	sym := c.define(node.Name.Value)
+
+	c.emit(code.OpConstant, c.addConstant(object.String(node.Name.Value)))
+	for _, f := range node.Fields {
+		c.emit(code.OpConstant, c.addConstant(object.String(f.Value)))
+	}
+	for _, m := range node.Methods {
+		c.emit(code.OpConstant, c.addConstant(object.String(m.Name.Value)))
+		err := c.compileFunctionLiteral(m.Function)
+		if err != nil {
+			return err
+		}
+	}
+	c.emit(code.OpRecordType, len(node.Fields), len(node.Methods))
+
+	c.storeSymbol(sym)
+	return nil
+}
+
+// compileDestructuringLetStatement emits an instruction replacing the value
+// with its parts, the first one on top of the stack, then stores each part
+func (c *Compiler) compileDestructuringLetStatement(node *ast.DestructuringLetStatement) error {
+	symbols := []Symbol{}
+	for _, name := range node.Names {
+		symbols = append(symbols, c.define(name.Value))
+	}
+	if node.Rest != nil {
+		symbols = append(symbols, c.define(node.Rest.Value))
+	}
+
+	err := c.Compile(node.Value)
+	if err != nil {
+		return err
+	}
+
+	if node.IsHash() {
+		for _, name := range node.Names {
+			c.emit(code.OpConstant, c.addConstant(object.String(name.Value)))
+		}
+		c.emit(code.OpDestructureHash, len(node.Names))
 	} else {
-		c.emit(code.OpSetLocal, sym.Index)
+		hasRest := 0
+		if node.Rest != nil {
+			hasRest = 1
+		}
+		c.emit(code.OpDestructureArray, len(node.Names), hasRest)
+	}
+
+	for _, sym := range symbols {
+		c.storeSymbol(sym)
 	}
 
 	return nil
@@ -402,6 +1243,61 @@ func (c *Compiler) compileIndexExpression(node *ast.IndexExpression) error {
 	return nil
 }
 
+// compileSliceExpression pushes the left operand and both bounds, with null
+// standing in for a bound that is left out
+func (c *Compiler) compileSliceExpression(node *ast.SliceExpression) error {
+	err := c.Compile(node.Left)
+	if err != nil {
+		return err
+	}
+
+	for _, bound := range []ast.Expression{node.Low, node.High} {
+		if bound == nil {
+			c.emit(code.OpNull)
+			continue
+		}
+		err = c.Compile(bound)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpSlice)
+	return nil
+}
+
+// define binds name in the current scope. A name already bound in the same
+// scope keeps its slot, so that a let statement rebinds it as in the
+// evaluator, e.g. `let i = i + 1` in a loop.
+func (c *Compiler) define(name string) Symbol {
+	if blocks := c.scopes[c.scopeIndex].blocks; len(blocks) > 0 {
+		block := blocks[len(blocks)-1]
+		if _, ok := block[name]; !ok {
+			sym, ok := c.symbolTable.store[name]
+			block[name] = hiddenSymbol{sym: sym, ok: ok}
+			return c.symbolTable.Define(name)
+		}
+	}
+
+	sym, ok := c.symbolTable.store[name]
+	if ok && (sym.Scope == GlobalScope || sym.Scope == LocalScope) {
+		return sym
+	}
+
+	return c.symbolTable.Define(name)
+}
+
+func (c *Compiler) storeSymbol(sym Symbol) {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, sym.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, sym.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, sym.Index)
+	}
+}
+
 func (c *Compiler) loadSymbol(sym Symbol) {
 	switch sym.Scope {
 	case GlobalScope:
@@ -417,6 +1313,20 @@ func (c *Compiler) loadSymbol(sym Symbol) {
 	}
 }
 
+// captureSymbol pushes a free variable of the closure being created. Local
+// and free variables are captured as cells shared with the enclosing
+// function rather than copied, so assignments are seen on both sides.
+func (c *Compiler) captureSymbol(sym Symbol) {
+	switch sym.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, sym.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, sym.Index)
+	default:
+		c.loadSymbol(sym)
+	}
+}
+
 // currentInstructions return the instructions of the current scope
 func (c *Compiler) currentInstructions() code.Instructions {
 	return c.scopes[c.scopeIndex].instructions
@@ -489,6 +1399,57 @@ func (c *Compiler) changeOperands(opPos, operand int) {
 	c.replaceInstruction(opPos, newInstruction)
 }
 
+// enterLoop starts a loop, saving the depth of the stack in a hidden variable
+// for its break and continue statements to reset the stack to
+func (c *Compiler) enterLoop() {
+	depth := c.define(fmt.Sprintf("$depth%d", len(c.scopes[c.scopeIndex].loops)))
+	c.emit(code.OpStackDepth)
+	c.storeSymbol(depth)
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loopJumps{tries: len(scope.tries), depth: depth})
+}
+
+// leaveLoop patches the jumps of the innermost loop: break jumps to the
+// end of the instructions compiled so far and continue to continuePos
+func (c *Compiler) leaveLoop(continuePos int) {
+	loops := c.scopes[c.scopeIndex].loops
+	loop := loops[len(loops)-1]
+	c.scopes[c.scopeIndex].loops = loops[:len(loops)-1]
+
+	endPos := len(c.currentInstructions())
+	for _, pos := range loop.breaks {
+		c.changeOperands(pos, endPos)
+	}
+	for _, pos := range loop.continues {
+		c.changeOperands(pos, continuePos)
+	}
+}
+
+// enterBlock starts a block scope: the names defined until the matching
+// leaveBlock get fresh symbols, which hide the symbols of the same names
+// outside the block
+func (c *Compiler) enterBlock() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.blocks = append(scope.blocks, blockScope{})
+}
+
+// leaveBlock ends the innermost block scope, making the symbols it hid
+// visible again
+func (c *Compiler) leaveBlock() {
+	scope := &c.scopes[c.scopeIndex]
+	block := scope.blocks[len(scope.blocks)-1]
+	scope.blocks = scope.blocks[:len(scope.blocks)-1]
+
+	for name, hidden := range block {
+		if hidden.ok {
+			c.symbolTable.store[name] = hidden.sym
+		} else {
+			delete(c.symbolTable.store, name)
+		}
+	}
+}
+
 func (c *Compiler) enterScope() {
 	newScope := CompilationScope{
 		instructions:        code.Instructions{},
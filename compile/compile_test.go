@@ -2,10 +2,13 @@ package compile
 
 import (
 	"fmt"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/dikaeinstein/monkey/code"
 	"github.com/dikaeinstein/monkey/object"
+	"github.com/dikaeinstein/monkey/parser"
 	"github.com/dikaeinstein/monkey/test"
 )
 
@@ -47,6 +50,66 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "2 % 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 & 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitAnd),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 | 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitOr),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 ^ 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpBitXor),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 << 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpShiftLeft),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "2 >> 1",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpShiftRight),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "2 / 1",
 			expectedConstants: []interface{}{2, 1},
@@ -93,6 +156,26 @@ func TestIntegerArithmetic(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input:             "1 >= 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 <= 2",
+			expectedConstants: []interface{}{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGreaterThanOrEqual),
+				code.Make(code.OpPop),
+			},
+		},
 		{
 			input:             "1 == 2",
 			expectedConstants: []interface{}{1, 2},
@@ -156,6 +239,86 @@ func TestIntegerArithmetic(t *testing.T) {
 	runCompilerTests(t, testCases)
 }
 
+func TestFloatArithmetic(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "1.5 * 2",
+			expectedConstants: []interface{}{1.5, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMul),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "-0.5",
+			expectedConstants: []interface{}{0.5},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "true && false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 12),
+				// 0008
+				code.Make(code.OpTrue),
+				// 0009
+				code.Make(code.OpJump, 13),
+				// 0012
+				code.Make(code.OpFalse),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "true || false",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 8),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJump, 17),
+				// 0008
+				code.Make(code.OpFalse),
+				// 0009
+				code.Make(code.OpJumpNotTruthy, 16),
+				// 0012
+				code.Make(code.OpTrue),
+				// 0013
+				code.Make(code.OpJump, 17),
+				// 0016
+				code.Make(code.OpFalse),
+				// 0017
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, testCases)
+}
+
 func TestConditionals(t *testing.T) {
 	testCases := []compilerTestCase{
 		{
@@ -182,6 +345,270 @@ func TestConditionals(t *testing.T) {
 				code.Make(code.OpPop),
 			},
 		},
+		{
+			input: `
+			if (true) { 10 } else if (false) { 20 } else { 30 }
+			`,
+			expectedConstants: []interface{}{10, 20, 30},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 23),
+				// 0010
+				code.Make(code.OpFalse),
+				// 0011
+				code.Make(code.OpJumpNotTruthy, 20),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpJump, 23),
+				// 0020
+				code.Make(code.OpConstant, 2),
+				// 0023
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, testCases)
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             `let [a, ...b] = [1];`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpDestructureArray, 1, 1),
+				// 0010
+				code.Make(code.OpSetGlobal, 0),
+				// 0013
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input:             `let h = {}; let {x, y} = h;`,
+			expectedConstants: []interface{}{"x", "y"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 0),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpDestructureHash, 2),
+				// 0018
+				code.Make(code.OpSetGlobal, 1),
+				// 0021
+				code.Make(code.OpSetGlobal, 2),
+			},
+		},
+	}
+	runCompilerTests(t, testCases)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             `match (1) { 2 => 3, [x] if x => x }`,
+			expectedConstants: []interface{}{1, 2, 3, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpEqual),
+				// 0013
+				code.Make(code.OpJumpNotTruthy, 22),
+				// 0016
+				code.Make(code.OpConstant, 2),
+				// 0019
+				code.Make(code.OpJump, 54),
+				// 0022
+				code.Make(code.OpGetGlobal, 0),
+				// 0025
+				code.Make(code.OpMatchArray, 1),
+				// 0028
+				code.Make(code.OpJumpNotTruthy, 53),
+				// 0031
+				code.Make(code.OpGetGlobal, 0),
+				// 0034
+				code.Make(code.OpConstant, 3),
+				// 0037
+				code.Make(code.OpIndex),
+				// 0038
+				code.Make(code.OpSetGlobal, 1),
+				// 0041
+				code.Make(code.OpGetGlobal, 1),
+				// 0044
+				code.Make(code.OpJumpNotTruthy, 53),
+				// 0047
+				code.Make(code.OpGetGlobal, 1),
+				// 0050
+				code.Make(code.OpJump, 54),
+				// 0053
+				code.Make(code.OpNull),
+				// 0054
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `match ({}) { {"a": _} => 1 }`,
+			expectedConstants: []interface{}{"a", 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpMatchHash),
+				// 0010
+				code.Make(code.OpJumpNotTruthy, 29),
+				// 0013
+				code.Make(code.OpGetGlobal, 0),
+				// 0016
+				code.Make(code.OpConstant, 0),
+				// 0019
+				code.Make(code.OpMatchKey),
+				// 0020
+				code.Make(code.OpJumpNotTruthy, 29),
+				// 0023
+				code.Make(code.OpConstant, 1),
+				// 0026
+				code.Make(code.OpJump, 30),
+				// 0029
+				code.Make(code.OpNull),
+				// 0030
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, testCases)
+}
+
+func TestLoops(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             `while (true) { break; continue; }`,
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpStackDepth),
+				// 0001
+				code.Make(code.OpSetGlobal, 0),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 25),
+				// 0008
+				code.Make(code.OpGetGlobal, 0),
+				// 0011
+				code.Make(code.OpResetStack),
+				// 0012
+				code.Make(code.OpJump, 25),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpResetStack),
+				// 0019
+				code.Make(code.OpJump, 4),
+				// 0022
+				code.Make(code.OpJump, 4),
+			},
+		},
+		{
+			input:             `for (let i = 0; i < 2; let i = i + 1) { continue; }`,
+			expectedConstants: []interface{}{0, 2, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpStackDepth),
+				// 0007
+				code.Make(code.OpSetGlobal, 1),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpGetGlobal, 0),
+				// 0016
+				code.Make(code.OpGreaterThan),
+				// 0017
+				code.Make(code.OpJumpNotTruthy, 40),
+				// 0020
+				code.Make(code.OpGetGlobal, 1),
+				// 0023
+				code.Make(code.OpResetStack),
+				// 0024
+				code.Make(code.OpJump, 27),
+				// 0027
+				code.Make(code.OpGetGlobal, 0),
+				// 0030
+				code.Make(code.OpConstant, 2),
+				// 0033
+				code.Make(code.OpAdd),
+				// 0034
+				code.Make(code.OpSetGlobal, 0),
+				// 0037
+				code.Make(code.OpJump, 10),
+			},
+		},
+		{
+			input:             `for (x in [1]) { x }`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpGetIter),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpStackDepth),
+				// 0011
+				code.Make(code.OpSetGlobal, 1),
+				// 0014
+				code.Make(code.OpGetGlobal, 0),
+				// 0017
+				code.Make(code.OpIterNext, 30),
+				// 0020
+				code.Make(code.OpSetGlobal, 2),
+				// 0023
+				code.Make(code.OpGetGlobal, 2),
+				// 0026
+				code.Make(code.OpPop),
+				// 0027
+				code.Make(code.OpJump, 14),
+				// 0030
+				code.Make(code.OpNull),
+				// 0031
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
 	}
 	runCompilerTests(t, testCases)
 }
@@ -260,6 +687,24 @@ func TestStringExpressions(t *testing.T) {
 	runCompilerTests(t, testCases)
 }
 
+func TestStringInterpolation(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             `"a${1}b"`,
+			expectedConstants: []interface{}{"a", 1, "b"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpInterpolate, 3),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	testCases := []compilerTestCase{
 		{
@@ -348,40 +793,159 @@ func TestHashLiterals(t *testing.T) {
 	runCompilerTests(t, testCases)
 }
 
-func TestIndexExpressions(t *testing.T) {
+func TestIndexExpressions(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "[1, 2, 3][1 + 1]",
+			expectedConstants: []interface{}{1, 2, 3, 1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpAdd),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "{1: 2}[2 - 1]",
+			expectedConstants: []interface{}{1, 2, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpSub),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "[1, 2][1:-1]",
+			expectedConstants: []interface{}{1, 2, 1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpMinus),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `"abc"[:2]`,
+			expectedConstants: []interface{}{"abc", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestTypeStatements(t *testing.T) {
 	testCases := []compilerTestCase{
 		{
-			input:             "[1, 2, 3][1 + 1]",
-			expectedConstants: []interface{}{1, 2, 3, 1, 1},
+			input: `type P { x, y, fn getX(self) { self.x } }; P(1, 2).y`,
+			expectedConstants: []interface{}{
+				"P",
+				"x",
+				"y",
+				"getX",
+				"x",
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 4),
+					code.Make(code.OpIndex),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				2,
+				"y",
+			},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpArray, 3),
 				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpAdd),
+				code.Make(code.OpClosure, 5, 0),
+				code.Make(code.OpRecordType, 2, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 6),
+				code.Make(code.OpConstant, 7),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpConstant, 8),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
 			},
 		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	testCases := []compilerTestCase{
 		{
-			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []interface{}{1, 2, 2, 1},
+			input: `fn(a, b = 1, c = b, ...d) { d }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpConstant, 0),
+					// 0003
+					code.Make(code.OpSetLocal, 1),
+					// 0005
+					code.Make(code.OpGetLocal, 1),
+					// 0007
+					code.Make(code.OpSetLocal, 2),
+					// 0009
+					code.Make(code.OpGetLocal, 3),
+					// 0011
+					code.Make(code.OpReturnValue),
+				},
+			},
 			expectedInstructions: []code.Instructions{
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 1),
-				code.Make(code.OpHash, 2),
-				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpSub),
-				code.Make(code.OpIndex),
+				code.Make(code.OpClosure, 1, 0),
 				code.Make(code.OpPop),
 			},
 		},
 	}
-
 	runCompilerTests(t, testCases)
+
+	program := test.Parse(testCases[0].input)
+	compiler := New(NewSymbolTable(), []object.Object{})
+	err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	fn := compiler.Bytecode().Constants[1].(*object.CompiledFunction)
+	if fn.NumParameters != 3 || fn.NumDefaults != 2 || !fn.Variadic || fn.NumLocals != 4 {
+		t.Errorf("wrong function arity. got=%+v", fn)
+	}
+	if !reflect.DeepEqual(fn.DefaultEntries, []int{0, 5, 9}) {
+		t.Errorf("wrong DefaultEntries. want=%v, got=%v", []int{0, 5, 9}, fn.DefaultEntries)
+	}
 }
 
 func TestFunctions(t *testing.T) {
@@ -730,6 +1294,99 @@ func TestBuiltins(t *testing.T) {
 	runCompilerTests(t, testCases)
 }
 
+func TestAssignExpressions(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             `let x = 1; x += 2;`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let a = [1]; a[0] = 3; a[0] += 2;`,
+			expectedConstants: []interface{}{1, 0, 3, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let h = {}; h.a = 3; h.a += 2;`,
+			expectedConstants: []interface{}{"a", 3, "a", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpDup, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			fn() {
+				let n = 0;
+				fn() { n = 1 }
+			}
+			`,
+			expectedConstants: []interface{}{
+				0,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 2, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
 func TestClosures(t *testing.T) {
 	testCases := []compilerTestCase{
 		{
@@ -748,7 +1405,7 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 0, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -778,13 +1435,13 @@ func TestClosures(t *testing.T) {
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 0, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 1, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -828,15 +1485,15 @@ func TestClosures(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpConstant, 2),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetFree, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureFree, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 4, 2),
 					code.Make(code.OpReturnValue),
 				},
 				[]code.Instructions{
 					code.Make(code.OpConstant, 1),
 					code.Make(code.OpSetLocal, 0),
-					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCaptureLocal, 0),
 					code.Make(code.OpClosure, 5, 1),
 					code.Make(code.OpReturnValue),
 				},
@@ -922,6 +1579,273 @@ func TestRecursiveFunctions(t *testing.T) {
 	runCompilerTests(t, testCases)
 }
 
+func TestCompilerErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "1:1: undefined variable: x"},
+		{"let a = 1;\nlet f = fn() {\n  a + b;\n};", "3:7: undefined variable: b"},
+		{"y = 1", "1:1: undefined variable: y"},
+		{"match (1) { y => y }; y", "1:23: undefined variable: y"},
+		{"len = 1", "1:1: cannot assign to len"},
+	}
+
+	for _, tC := range testCases {
+		program := test.Parse(tC.input)
+
+		compiler := NewCompilerWithBuiltins([]object.Object{})
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+		if err.Error() != tC.expected {
+			t.Errorf("wrong compiler error: want=%q, got=%q", tC.expected, err)
+		}
+	}
+}
+
+func TestTryStatements(t *testing.T) {
+	testCases := []compilerTestCase{
+		{
+			input:             "try { throw 1 } catch (e) { e }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 12),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpThrow),
+				// 0007
+				code.Make(code.OpNull),
+				// 0008
+				code.Make(code.OpEndTry),
+				// 0009
+				code.Make(code.OpJump, 21),
+				// 0012
+				code.Make(code.OpSetGlobal, 0),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpJump, 21),
+				// 0021
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "try { 1 } finally { 2 }",
+			expectedConstants: []interface{}{1, 2, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 17),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpSetGlobal, 0),
+				// 0009
+				code.Make(code.OpEndTry),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpJump, 28),
+				// 0017
+				code.Make(code.OpSetGlobal, 1),
+				// 0020
+				code.Make(code.OpConstant, 2),
+				// 0023
+				code.Make(code.OpPop),
+				// 0024
+				code.Make(code.OpGetGlobal, 1),
+				// 0027
+				code.Make(code.OpThrow),
+				// 0028
+				code.Make(code.OpGetGlobal, 0),
+				// 0031
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "while (true) { try { break } finally { 1 } }",
+			expectedConstants: []interface{}{1, 1, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpStackDepth),
+				// 0001
+				code.Make(code.OpSetGlobal, 0),
+				// 0004
+				code.Make(code.OpTrue),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 53),
+				// 0008
+				code.Make(code.OpTry, 35),
+				// 0011
+				code.Make(code.OpEndTry),
+				// 0012
+				code.Make(code.OpConstant, 0),
+				// 0015
+				code.Make(code.OpPop),
+				// 0016
+				code.Make(code.OpGetGlobal, 0),
+				// 0019
+				code.Make(code.OpResetStack),
+				// 0020
+				code.Make(code.OpJump, 53),
+				// 0023
+				code.Make(code.OpNull),
+				// 0024
+				code.Make(code.OpSetGlobal, 1),
+				// 0027
+				code.Make(code.OpEndTry),
+				// 0028
+				code.Make(code.OpConstant, 1),
+				// 0031
+				code.Make(code.OpPop),
+				// 0032
+				code.Make(code.OpJump, 46),
+				// 0035
+				code.Make(code.OpSetGlobal, 2),
+				// 0038
+				code.Make(code.OpConstant, 2),
+				// 0041
+				code.Make(code.OpPop),
+				// 0042
+				code.Make(code.OpGetGlobal, 2),
+				// 0045
+				code.Make(code.OpThrow),
+				// 0046
+				code.Make(code.OpGetGlobal, 1),
+				// 0049
+				code.Make(code.OpPop),
+				// 0050
+				code.Make(code.OpJump, 4),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestImportStatements(t *testing.T) {
+	dir := t.TempDir()
+	err := test.WriteFiles(dir, map[string]string{"lib.monkey": "let a = 1; export let b = a;"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "lib.monkey")
+
+	testCases := []compilerTestCase{
+		{
+			input:             fmt.Sprintf(`import "%[1]s" as x; import "%[1]s" as y;`, path),
+			expectedConstants: []interface{}{1, path, "b"},
+			expectedInstructions: []code.Instructions{
+				// module
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpModule, 2),
+				code.Make(code.OpSetGlobal, 2),
+				// import as x
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpSetGlobal, 3),
+				// import as y
+				code.Make(code.OpGetGlobal, 2),
+				code.Make(code.OpSetGlobal, 4),
+			},
+		},
+	}
+
+	runCompilerTests(t, testCases)
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	err := test.WriteFiles(dir, map[string]string{
+		"a.monkey":         `import "b.monkey" as b`,
+		"b.monkey":         `import "a.monkey" as a`,
+		"syntax.monkey":    `let = 1`,
+		"undefined.monkey": "let x = 1;\ny",
+		"macro.monkey":     "let m = macro(x) { x }; m(1)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`import "%s/missing.monkey" as m`,
+			"1:8: cannot import %[1]s/missing.monkey: open %[1]s/missing.monkey: no such file or directory",
+		},
+		{
+			`import "%s/syntax.monkey" as m`,
+			"1:8: cannot import %[1]s/syntax.monkey: %[1]s/syntax.monkey:1:5: expected next token to be IDENT, got = instead",
+		},
+		{
+			`let y = 1; import "%s/undefined.monkey" as m`,
+			"%s/undefined.monkey:2:1: undefined variable: y",
+		},
+		{
+			`import "%s/a.monkey" as a`,
+			"%[1]s/b.monkey:1:8: import cycle: %[1]s/a.monkey -> %[1]s/b.monkey -> %[1]s/a.monkey",
+		},
+		{
+			`import "%s/macro.monkey" as m`,
+			"1:8: cannot import %[1]s/macro.monkey: it defines macros and the compiler has no macro evaluator",
+		},
+	}
+
+	for _, tC := range testCases {
+		program := test.Parse(fmt.Sprintf(tC.input, dir))
+
+		compiler := NewCompilerWithBuiltins([]object.Object{})
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+
+		expected := fmt.Sprintf(tC.expected, dir)
+		if err.Error() != expected {
+			t.Errorf("wrong compiler error: want=%q, got=%q", expected, err)
+		}
+	}
+}
+
+func TestImportCycleFromFile(t *testing.T) {
+	dir := t.TempDir()
+	err := test.WriteFiles(dir, map[string]string{
+		"a.monkey": `import "b.monkey" as b`,
+		"b.monkey": `import "a.monkey" as a`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := parser.ParseFile(filepath.Join(dir, "a.monkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compiler := NewCompilerWithBuiltins([]object.Object{})
+	err = compiler.Compile(program)
+	if err == nil {
+		t.Fatalf("expected compiler error but resulted in none.")
+	}
+
+	expected := fmt.Sprintf("%[1]s/b.monkey:1:8: import cycle: %[1]s/a.monkey -> %[1]s/b.monkey -> %[1]s/a.monkey", dir)
+	if err.Error() != expected {
+		t.Errorf("wrong compiler error: want=%q, got=%q", expected, err)
+	}
+}
+
 func runCompilerTests(t *testing.T, testCases []compilerTestCase) {
 	t.Helper()
 
@@ -988,6 +1912,15 @@ func testConstants(expected []interface{}, actual []object.Object) error {
 				return fmt.Errorf("constant %d - testIntegerObject failed: %s",
 					i, err)
 			}
+		case float64:
+			result, ok := actual[i].(object.Float)
+			if !ok {
+				return fmt.Errorf("constant %d - not a Float: %T", i, actual[i])
+			}
+			if float64(result) != constant {
+				return fmt.Errorf("constant %d - wrong value. got=%g, want=%g",
+					i, result, constant)
+			}
 		case string:
 			err := testStringObject(constant, actual[i])
 			if err != nil {
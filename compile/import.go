@@ -0,0 +1,100 @@
+package compile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dikaeinstein/monkey/ast"
+	"github.com/dikaeinstein/monkey/code"
+	"github.com/dikaeinstein/monkey/macro"
+	"github.com/dikaeinstein/monkey/object"
+	"github.com/dikaeinstein/monkey/parser"
+)
+
+func (c *Compiler) compileImportStatement(node *ast.ImportStatement) error {
+	module, err := c.compileModule(node)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpGetGlobal, module.Index)
+	sym := c.define(node.Name.Value)
+	c.emit(code.OpSetGlobal, sym.Index)
+
+	return nil
+}
+
+// compileModule compiles the module imported by node in place the first time
+// it is imported. The top-level names of the module are globals of its own,
+// and its value is kept in a hidden global, which is returned.
+func (c *Compiler) compileModule(node *ast.ImportStatement) (Symbol, error) {
+	path := node.File()
+
+	if sym, ok := c.modules[path]; ok {
+		return sym, nil
+	}
+
+	if len(c.importing) == 0 && node.Token.Pos.Filename != "" {
+		// the file importing node is the root of the imports, so a cycle
+		// back to it is reported where it closes
+		c.importing = []string{filepath.Clean(node.Token.Pos.Filename)}
+		defer func() { c.importing = nil }()
+	}
+
+	for i, importing := range c.importing {
+		if importing == path {
+			cycle := append(c.importing[i:len(c.importing):len(c.importing)], path)
+			return Symbol{}, fmt.Errorf("%s: import cycle: %s",
+				node.Path.Pos(), strings.Join(cycle, " -> "))
+		}
+	}
+
+	program, err := parser.ParseFile(path)
+	if err != nil {
+		return Symbol{}, fmt.Errorf("%s: cannot import %s: %s", node.Path.Pos(), path, err)
+	}
+
+	macroEnv := object.NewEnvironment()
+	if macro.Define(program, macroEnv) > 0 && c.evalMacro == nil {
+		return Symbol{}, fmt.Errorf("%s: cannot import %s: it defines macros and the compiler has no macro evaluator",
+			node.Path.Pos(), path)
+	}
+	expanded := macro.Expand(program, macroEnv, c.evalMacro)
+
+	importer := c.symbolTable
+	c.symbolTable = NewModuleSymbolTable(importer)
+	c.importing = append(c.importing, path)
+
+	err = c.Compile(expanded)
+
+	moduleTable := c.symbolTable
+	c.symbolTable = importer
+	c.importing = c.importing[:len(c.importing)-1]
+
+	if err != nil {
+		return Symbol{}, err
+	}
+
+	numOfElements := 0
+	c.emit(code.OpConstant, c.addConstant(object.String(path)))
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		for _, name := range export.Names() {
+			sym, _ := moduleTable.Resolve(name.Value)
+			c.emit(code.OpConstant, c.addConstant(object.String(name.Value)))
+			c.emit(code.OpGetGlobal, sym.Index)
+			numOfElements += 2
+		}
+	}
+	c.emit(code.OpModule, numOfElements)
+
+	sym := c.define(fmt.Sprintf("$module%d", len(c.modules)))
+	c.emit(code.OpSetGlobal, sym.Index)
+	c.modules[path] = sym
+
+	return sym, nil
+}
@@ -26,16 +26,41 @@ type SymbolTable struct {
 
 	store          map[string]Symbol
 	numDefinitions int
+	// globals counts the globals defined by a program and the modules it
+	// imports, which share one globals store. It is nil in enclosed tables.
+	globals *int
 
 	FreeSymbols []Symbol
 }
 
 func NewSymbolTable() *SymbolTable {
 	return &SymbolTable{
-		store: make(map[string]Symbol),
+		store:   make(map[string]Symbol),
+		globals: new(int),
 	}
 }
 
+// NewModuleSymbolTable returns the symbol table of a module imported by the
+// program whose symbol table is st. The module has globals of its own, taken
+// from the same globals store, and sees the same builtins.
+func NewModuleSymbolTable(st *SymbolTable) *SymbolTable {
+	for st.parent != nil {
+		st = st.parent
+	}
+
+	module := &SymbolTable{
+		store:   make(map[string]Symbol),
+		globals: st.globals,
+	}
+	for name, sym := range st.store {
+		if sym.Scope == BuiltinScope {
+			module.store[name] = sym
+		}
+	}
+
+	return module
+}
+
 func NewEnclosedSymbolTable(parent *SymbolTable) *SymbolTable {
 	return &SymbolTable{
 		parent: parent,
@@ -48,6 +73,8 @@ func (st *SymbolTable) Define(ident string) Symbol {
 
 	if st.parent == nil {
 		sym.Scope = GlobalScope
+		sym.Index = *st.globals
+		*st.globals++
 	} else {
 		sym.Scope = LocalScope
 	}
@@ -184,6 +184,34 @@ func TestDefineResolveBuiltins(t *testing.T) {
 	}
 }
 
+func TestModuleSymbolTable(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("a")
+
+	module := NewModuleSymbolTable(global)
+	b := module.Define("a")
+	c := global.Define("c")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 1},
+		{Name: "c", Scope: GlobalScope, Index: 2},
+	}
+	for i, sym := range []Symbol{b, c} {
+		if sym != expected[i] {
+			t.Errorf("expected %+v, got=%+v", expected[i], sym)
+		}
+	}
+
+	builtin := Symbol{Name: "len", Scope: BuiltinScope, Index: 0}
+	if sym, ok := module.Resolve("len"); !ok || sym != builtin {
+		t.Errorf("expected len to resolve to %+v, got=%+v", builtin, sym)
+	}
+	if _, ok := module.Resolve("c"); ok {
+		t.Errorf("name c resolvable in module")
+	}
+}
+
 func TestResolveFree(t *testing.T) {
 	global := NewSymbolTable()
 	global.Define("a")
@@ -10,4 +10,6 @@ var builtins = map[string]object.BuiltInFunction{
 	"rest":  object.GetBuiltinByName("rest"),
 	"push":  object.GetBuiltinByName("push"),
 	"puts":  object.GetBuiltinByName("puts"),
+	"int":   object.GetBuiltinByName("int"),
+	"float": object.GetBuiltinByName("float"),
 }
@@ -2,6 +2,9 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"strings"
 
 	"github.com/dikaeinstein/monkey/ast"
 	"github.com/dikaeinstein/monkey/object"
@@ -14,33 +17,76 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalStatements(node.Statements, env)
+		return uncaught(unwrapReturnValue(evalStatements(node.Statements, env)))
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.BlockStatement:
 		return evalStatements(node.Statements, env)
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
-		if object.IsError(val) {
+		if unwinds(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 		return nil
+	case *ast.DestructuringLetStatement:
+		val := Eval(node.Value, env)
+		if unwinds(val) {
+			return val
+		}
+		return withPosition(node.Value.Pos(), evalDestructuring(node, val, env))
+	case *ast.TypeStatement:
+		env.Set(node.Name.Value, evalTypeStatement(node, env))
+		return nil
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
 	case *ast.ReturnStatement:
-		return Eval(node.ReturnValue, env)
+		val := Eval(node.ReturnValue, env)
+		if unwinds(val) {
+			return val
+		}
+		return &returnValue{value: val}
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.TryStatement:
+		return evalTryStatement(node, env)
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if unwinds(val) {
+			return val
+		}
+		return &thrownValue{value: val, pos: node.Token.Pos}
+	case *ast.BreakStatement:
+		return breakSignal
+	case *ast.ContinueStatement:
+		return continueSignal
 	// Expressions
 	case *ast.IntegerLiteral:
 		return object.Integer(node.Value)
+	case *ast.FloatLiteral:
+		return object.Float(node.Value)
 	case *ast.StringLiteral:
 		return object.String(node.Value)
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.Boolean:
 		return object.Boolean(node.Value)
 	case *ast.PrefixExpression:
 		return evalPrefixExpression(node, env)
 	case *ast.InfixExpression:
 		return evalInfixExpression(node, env)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
@@ -60,7 +106,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if object.IsError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return withPosition(node.Token.Pos, evalIndexExpression(left, index))
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.SelectorExpression:
+		left := Eval(node.Left, env)
+		if object.IsError(left) {
+			return left
+		}
+		return withPosition(node.Token.Pos, evalIndexExpression(left, object.String(node.Name.Value)))
 	default:
 		return nil
 	}
@@ -70,11 +124,8 @@ func evalStatements(statements []ast.Statement, env *object.Environment) object.
 	var result object.Object
 
 	for _, stmt := range statements {
-		if _, ok := stmt.(*ast.ReturnStatement); ok {
-			return Eval(stmt, env)
-		}
 		result = Eval(stmt, env)
-		if object.IsError(result) {
+		if unwinds(result) {
 			return result
 		}
 	}
@@ -82,45 +133,423 @@ func evalStatements(statements []ast.Statement, env *object.Environment) object.
 	return result
 }
 
+// returnValue carries the value of a return statement out of the
+// enclosing blocks and loops up to the function call or program
+type returnValue struct {
+	value object.Object
+}
+
+func (rv *returnValue) Type() object.Type { return "RETURN_VALUE" }
+func (rv *returnValue) Inspect() string   { return rv.value.Inspect() }
+
+// thrownValue carries the value of a throw statement out of the enclosing
+// blocks and function calls up to the innermost try statement. It has the
+// ERROR type so that it propagates the way errors do.
+type thrownValue struct {
+	value object.Object
+	pos   token.Position
+}
+
+func (tv *thrownValue) Type() object.Type { return object.ERROR }
+func (tv *thrownValue) Inspect() string   { return tv.value.Inspect() }
+
+// uncaught turns a value thrown out of the program into an error
+func uncaught(obj object.Object) object.Object {
+	if tv, ok := obj.(*thrownValue); ok {
+		return withPosition(tv.pos, newError("uncaught exception: %s", tv.value.Inspect()))
+	}
+
+	return obj
+}
+
+// loopSignal is produced by a break or continue statement and carried out
+// of the enclosing blocks up to the innermost loop. Like thrownValue, it has
+// the ERROR type so that it propagates out of expressions the way errors do.
+type loopSignal string
+
+const (
+	breakSignal    loopSignal = "break"
+	continueSignal loopSignal = "continue"
+)
+
+func (ls loopSignal) Type() object.Type { return object.ERROR }
+func (ls loopSignal) Inspect() string   { return string(ls) }
+
+// unwinds reports whether obj stops the evaluation of the enclosing
+// statements: an error, a return value or a loop signal
+func unwinds(obj object.Object) bool {
+	switch obj.(type) {
+	case *returnValue, loopSignal:
+		return true
+	default:
+		return object.IsError(obj)
+	}
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if rv, ok := obj.(*returnValue); ok {
+		return rv.value
+	}
+
+	return obj
+}
+
+// evalDestructuring binds the names of a destructuring let statement to
+// the elements or hash values of val. It returns an error, and binds
+// nothing, if val does not have the shape of the pattern.
+func evalDestructuring(node *ast.DestructuringLetStatement, val object.Object, env *object.Environment) object.Object {
+	if node.IsHash() {
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return newError("cannot destructure %s as HASH", val.Type())
+		}
+
+		values := make([]object.Object, len(node.Names))
+		for i, name := range node.Names {
+			v, ok := hash.Pairs[object.String(name.Value)]
+			if !ok {
+				return newError("cannot destructure missing key: %s", name.Value)
+			}
+			values[i] = v
+		}
+
+		for i, name := range node.Names {
+			env.Set(name.Value, values[i])
+		}
+		return nil
+	}
+
+	array, ok := val.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s as ARRAY", val.Type())
+	}
+
+	n := len(node.Names)
+	switch {
+	case node.Rest == nil && len(array.Elements) != n:
+		return newError("wrong number of elements to destructure: want=%d, got=%d",
+			n, len(array.Elements))
+	case len(array.Elements) < n:
+		return newError("not enough elements to destructure: want at least %d, got %d",
+			n, len(array.Elements))
+	}
+
+	for i, name := range node.Names {
+		env.Set(name.Value, array.Elements[i])
+	}
+	if node.Rest != nil {
+		rest := make([]object.Object, len(array.Elements)-n)
+		copy(rest, array.Elements[n:])
+		env.Set(node.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	return nil
+}
+
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if object.IsError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if result, done := evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	if node.Init != nil {
+		if init := Eval(node.Init, env); object.IsError(init) {
+			return init
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			condition := Eval(node.Condition, env)
+			if object.IsError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+
+		if result, done := evalLoopBody(node.Body, env); done {
+			return result
+		}
+
+		if node.Post != nil {
+			if post := Eval(node.Post, env); object.IsError(post) {
+				return post
+			}
+		}
+	}
+}
+
+func evalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if object.IsError(iterable) {
+		return iterable
+	}
+
+	it, ok := object.NewIterator(iterable)
+	if !ok {
+		return withPosition(node.Iterable.Pos(),
+			newError("cannot iterate over %s", iterable.Type()))
+	}
+
+	for {
+		element, ok := it.Next()
+		if !ok {
+			return nil
+		}
+		env.Set(node.Variable.Value, element)
+
+		if result, done := evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+// evalLoopBody evaluates one iteration of a loop. done reports whether the
+// loop has to stop, in which case result is what the loop evaluates to.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	result = Eval(body, env)
+
+	switch {
+	case result == breakSignal:
+		return nil, true
+	case result == continueSignal:
+		return nil, false
+	case unwinds(result):
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// evalTryStatement evaluates the try block. A value thrown or an error raised
+// in it is bound to the catch parameter, as the error message without its
+// position for an error, in an environment enclosed by env, and the catch
+// block is evaluated. The finally block is always evaluated last, and a
+// return, break, throw or error in it takes over from any in the other
+// blocks. Otherwise the statement evaluates to the try or catch block.
+func evalTryStatement(node *ast.TryStatement, env *object.Environment) object.Object {
+	result := Eval(node.Body, env)
+
+	if node.Catch != nil {
+		var caught object.Object
+		switch r := result.(type) {
+		case *thrownValue:
+			caught = r.value
+		case object.Error:
+			caught = errorMessage(r)
+		}
+
+		if caught != nil {
+			catchEnv := object.NewEnclosedEnvironment(env)
+			catchEnv.Set(node.Param.Value, caught)
+			result = Eval(node.Catch, catchEnv)
+		}
+	}
+
+	if node.Finally != nil {
+		if finally := Eval(node.Finally, env); unwinds(finally) {
+			return finally
+		}
+	}
+
+	if result == nil {
+		return object.NullValue()
+	}
+	return result
+}
+
 func evalPrefixExpression(node *ast.PrefixExpression, env *object.Environment) object.Object {
 	right := Eval(node.Right, env)
 	if object.IsError(right) {
 		return right
 	}
 
-	switch node.Operator {
+	return withPosition(node.Token.Pos, evalPrefixOperatorExpression(node.Operator, right))
+}
+
+func evalPrefixOperatorExpression(operator string, right object.Object) object.Object {
+	switch operator {
 	case string(token.BANG):
 		return evalBangOperatorExpression(right)
 	case string(token.MINUS):
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", node.Operator, right.Type())
+		return newError("unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalInfixExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	if node.Operator == string(token.AND) || node.Operator == string(token.OR) {
+		return evalLogicalExpression(node, env)
+	}
+
+	left := Eval(node.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+	right := Eval(node.Right, env)
+	if object.IsError(right) {
+		return right
+	}
+
+	return withPosition(node.Token.Pos, evalInfixOperatorExpression(node.Operator, left, right))
+}
+
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated if the left one does not already decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
 	left := Eval(node.Left, env)
 	if object.IsError(left) {
 		return left
 	}
+
+	if isTruthy(left) == (node.Operator == string(token.OR)) {
+		return object.Boolean(isTruthy(left))
+	}
+
 	right := Eval(node.Right, env)
 	if object.IsError(right) {
 		return right
 	}
 
+	return object.Boolean(isTruthy(right))
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		return evalVariableAssignment(node, target, env)
+	case *ast.IndexExpression:
+		return evalIndexAssignment(node, target, env)
+	case *ast.SelectorExpression:
+		return evalSelectorAssignment(node, target, env)
+	default:
+		return withPosition(node.Target.Pos(),
+			newError("cannot assign to %s", node.Target.String()))
+	}
+}
+
+func evalVariableAssignment(node *ast.AssignExpression, ident *ast.Identifier, env *object.Environment) object.Object {
+	var current object.Object
+	if node.Operator != string(token.ASSIGN) {
+		var ok bool
+		if current, ok = env.Get(ident.Value); !ok {
+			return withPosition(ident.Pos(), newError("identifier not found: "+ident.Value))
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if object.IsError(val) {
+		return val
+	}
+
+	val = applyAssignOperator(node, current, val)
+	if object.IsError(val) {
+		return val
+	}
+
+	if !env.Assign(ident.Value, val) {
+		return withPosition(ident.Pos(), newError("identifier not found: "+ident.Value))
+	}
+
+	return val
+}
+
+func evalIndexAssignment(node *ast.AssignExpression, target *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+	index := Eval(target.Index, env)
+	if object.IsError(index) {
+		return index
+	}
+
+	return assignIndex(node, left, index, target.Token.Pos, env)
+}
+
+func evalSelectorAssignment(node *ast.AssignExpression, target *ast.SelectorExpression, env *object.Environment) object.Object {
+	left := Eval(target.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+
+	return assignIndex(node, left, object.String(target.Name.Value), target.Token.Pos, env)
+}
+
+// assignIndex assigns the value of node to the element of left at index.
+// Errors raised by the indexing are reported at pos.
+func assignIndex(node *ast.AssignExpression, left, index object.Object, pos token.Position, env *object.Environment) object.Object {
+	var current object.Object
+	if node.Operator != string(token.ASSIGN) {
+		current = withPosition(pos, evalIndexExpression(left, index))
+		if object.IsError(current) {
+			return current
+		}
+	}
+
+	val := Eval(node.Value, env)
+	if object.IsError(val) {
+		return val
+	}
+
+	val = applyAssignOperator(node, current, val)
+	if object.IsError(val) {
+		return val
+	}
+
+	if err := withPosition(pos, evalSetIndex(left, index, val)); object.IsError(err) {
+		return err
+	}
+
+	return val
+}
+
+// applyAssignOperator combines the current value with the assigned value
+// for a compound assignment such as +=. For a plain assignment it returns
+// val unchanged.
+func applyAssignOperator(node *ast.AssignExpression, current, val object.Object) object.Object {
+	if node.Operator == string(token.ASSIGN) {
+		return val
+	}
+
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return withPosition(node.Token.Pos, evalInfixOperatorExpression(operator, current, val))
+}
+
+func evalInfixOperatorExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.BOOLEAN && right.Type() == object.BOOLEAN:
-		return evalBooleanInfixExpression(node.Operator, left, right)
+		return evalBooleanInfixExpression(operator, left, right)
 	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
-		return evalIntegerInfixExpression(node.Operator, left, right)
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING && right.Type() == object.STRING:
-		return evalStringInfixExpression(node.Operator, left, right)
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.RECORD && right.Type() == object.RECORD:
+		return evalRecordInfixExpression(operator, left, right)
+	case left.Type() == object.MODULE && right.Type() == object.MODULE:
+		return evalModuleInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s",
-			left.Type(), node.Operator, right.Type())
+			left.Type(), operator, right.Type())
 	default:
 		return newError("unknown operator: %s %s %s",
-			left.Type(), node.Operator, right.Type())
+			left.Type(), operator, right.Type())
 	}
 }
 
@@ -135,8 +564,63 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return object.Integer(lVal - rVal)
 	case string(token.ASTERISK):
 		return object.Integer(lVal * rVal)
-	case string(token.SLASH):
+	case string(token.SLASH), string(token.PERCENT):
+		if rVal == 0 {
+			return newError("division by zero")
+		}
+		if operator == string(token.PERCENT) {
+			return object.Integer(lVal % rVal)
+		}
 		return object.Integer(lVal / rVal)
+	case string(token.AMPERSAND):
+		return object.Integer(lVal & rVal)
+	case string(token.PIPE):
+		return object.Integer(lVal | rVal)
+	case string(token.CARET):
+		return object.Integer(lVal ^ rVal)
+	case string(token.ShiftLeft), string(token.ShiftRight):
+		if rVal < 0 {
+			return newError("negative shift count: %d", rVal)
+		}
+		if operator == string(token.ShiftLeft) {
+			return object.Integer(lVal << rVal)
+		}
+		return object.Integer(lVal >> rVal)
+	case string(token.EQ):
+		return object.Boolean(lVal == rVal)
+	case string(token.NotEQ):
+		return object.Boolean(lVal != rVal)
+	case string(token.GT):
+		return object.Boolean(lVal > rVal)
+	case string(token.LT):
+		return object.Boolean(lVal < rVal)
+	case string(token.GtEQ):
+		return object.Boolean(lVal >= rVal)
+	case string(token.LtEQ):
+		return object.Boolean(lVal <= rVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression evaluates an infix expression where at least one
+// operand is a float; an integer operand is converted to a float
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	lVal, _ := object.AsFloat(left)
+	rVal, _ := object.AsFloat(right)
+
+	switch operator {
+	case string(token.PLUS):
+		return object.Float(lVal + rVal)
+	case string(token.MINUS):
+		return object.Float(lVal - rVal)
+	case string(token.ASTERISK):
+		return object.Float(lVal * rVal)
+	case string(token.SLASH):
+		return object.Float(lVal / rVal)
+	case string(token.PERCENT):
+		return object.Float(math.Mod(lVal, rVal))
 	case string(token.EQ):
 		return object.Boolean(lVal == rVal)
 	case string(token.NotEQ):
@@ -145,6 +629,10 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return object.Boolean(lVal > rVal)
 	case string(token.LT):
 		return object.Boolean(lVal < rVal)
+	case string(token.GtEQ):
+		return object.Boolean(lVal >= rVal)
+	case string(token.LtEQ):
+		return object.Boolean(lVal <= rVal)
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -170,12 +658,53 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	lVal := left.(object.String)
 	rVal := right.(object.String)
 
-	if operator != string(token.PLUS) {
+	switch operator {
+	case string(token.PLUS):
+		return lVal + rVal
+	case string(token.EQ):
+		return object.Boolean(lVal == rVal)
+	case string(token.NotEQ):
+		return object.Boolean(lVal != rVal)
+	case string(token.GT):
+		return object.Boolean(lVal > rVal)
+	case string(token.LT):
+		return object.Boolean(lVal < rVal)
+	case string(token.GtEQ):
+		return object.Boolean(lVal >= rVal)
+	case string(token.LtEQ):
+		return object.Boolean(lVal <= rVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func evalRecordInfixExpression(operator string, left, right object.Object) object.Object {
+	equal := left.(*object.Record).Equal(right.(*object.Record))
+
+	switch operator {
+	case string(token.EQ):
+		return object.Boolean(equal)
+	case string(token.NotEQ):
+		return object.Boolean(!equal)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+}
 
-	return lVal + rVal
+// evalModuleInfixExpression compares modules by identity, as a module is
+// loaded once however its path is written
+func evalModuleInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case string(token.EQ):
+		return object.Boolean(left == right)
+	case string(token.NotEQ):
+		return object.Boolean(left != right)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
 }
 
 func evalBangOperatorExpression(right object.Object) object.Object {
@@ -190,11 +719,14 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	intVal, ok := right.(object.Integer)
-	if !ok {
+	switch right := right.(type) {
+	case object.Integer:
+		return -right
+	case object.Float:
+		return -right
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	return object.Integer(-int64(intVal))
 }
 
 func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
@@ -204,11 +736,107 @@ func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Ob
 	}
 	if isTruthy(condition) {
 		return Eval(node.Consequence, env)
-	} else if node.Alternative != nil {
+	}
+
+	for _, e := range node.ElseIfs {
+		condition := Eval(e.Condition, env)
+		if object.IsError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return Eval(e.Consequence, env)
+		}
+	}
+
+	if node.Alternative != nil {
 		return Eval(node.Alternative, env)
-	} else {
-		return object.NullValue()
 	}
+	return object.NullValue()
+}
+
+// evalMatchExpression evaluates the body of the first arm matching the
+// subject, or returns null if there is none. Each arm binds the names in its
+// pattern in an environment of its own, enclosed by env, so they are only
+// visible to its guard and body.
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(node.Subject, env)
+	if unwinds(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+		if !matchPattern(arm.Pattern, subject, armEnv) {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if unwinds(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return object.NullValue()
+}
+
+// matchPattern reports whether val matches pattern, binding the names in
+// the pattern in env as it goes
+func matchPattern(pattern ast.Pattern, val object.Object, env *object.Environment) bool {
+	switch pattern := pattern.(type) {
+	case *ast.WildcardPattern:
+		return true
+	case *ast.BindingPattern:
+		env.Set(pattern.Name.Value, val)
+		return true
+	case *ast.LiteralPattern:
+		return matchLiteral(Eval(pattern.Value, env), val)
+	case *ast.ArrayPattern:
+		array, ok := val.(*object.Array)
+		if !ok || len(array.Elements) != len(pattern.Elements) {
+			return false
+		}
+		for i, el := range pattern.Elements {
+			if !matchPattern(el, array.Elements[i], env) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false
+		}
+		for i, k := range pattern.Keys {
+			key, ok := object.IsHashable(Eval(k, env))
+			if !ok {
+				return false
+			}
+			v, ok := hash.Pairs[key]
+			if !ok || !matchPattern(pattern.Values[i], v, env) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// matchLiteral reports whether val is equal to the literal, using the same
+// comparison as ==. Values of unrelated types never match.
+func matchLiteral(literal, val object.Object) bool {
+	if literal.Type() != val.Type() && !(isNumber(literal) && isNumber(val)) {
+		return false
+	}
+
+	return evalInfixOperatorExpression("==", val, literal) == object.Boolean(true)
 }
 
 // evalIdentifier resolve names in this order: (local, enclosing, global, builtin)
@@ -221,17 +849,35 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return withPosition(node.Token.Pos, newError("identifier not found: "+node.Value))
 }
 
 func evalFunction(node *ast.FunctionLiteral, env *object.Environment) object.Object {
 	return &object.Function{
 		Parameters: node.Parameters,
+		Defaults:   node.Defaults,
+		Rest:       node.Rest,
 		Body:       node.Body,
 		Env:        env,
 	}
 }
 
+func evalTypeStatement(node *ast.TypeStatement, env *object.Environment) *object.RecordType {
+	rt := &object.RecordType{
+		Name:    node.Name.Value,
+		Fields:  make([]string, len(node.Fields)),
+		Methods: make(map[string]object.Object, len(node.Methods)),
+	}
+	for i, f := range node.Fields {
+		rt.Fields[i] = f.Value
+	}
+	for _, m := range node.Methods {
+		rt.Methods[m.Name.Value] = evalFunction(m.Function, env)
+	}
+
+	return rt
+}
+
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -261,28 +907,80 @@ func evalCallExpression(node *ast.CallExpression, env *object.Environment) objec
 		return args[0]
 	}
 
-	return applyFunction(fn, args)
+	if method, ok := fn.(*object.BoundMethod); ok {
+		fn = method.Method
+		args = append([]object.Object{method.Receiver}, args...)
+	}
+
+	// errors raised inside a function body already carry their position
+	if fn, ok := fn.(*object.Function); ok {
+		if err := checkArity(fn, len(args)); err != nil {
+			return withPosition(node.Token.Pos, err)
+		}
+		return applyFunction(fn, args)
+	}
+
+	return withPosition(node.Token.Pos, applyFunction(fn, args))
 }
 
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if err := checkArity(fn, len(args)); err != nil {
+			return err
+		}
+
 		env := object.NewEnclosedEnvironment(fn.Env)
-		// bind arguments to parameters in the function stack frame a.k.a scope
-		for i, arg := range args {
-			ident := fn.Parameters[i]
-			env.Set(ident.Value, arg)
+		// bind arguments to parameters in the function stack frame a.k.a scope,
+		// the default values are evaluated in it for the missing ones
+		required := len(fn.Parameters) - len(fn.Defaults)
+		for i, ident := range fn.Parameters {
+			if i < len(args) {
+				env.Set(ident.Value, args[i])
+				continue
+			}
+
+			val := Eval(fn.Defaults[i-required], env)
+			if object.IsError(val) {
+				return val
+			}
+			env.Set(ident.Value, val)
+		}
+		if fn.Rest != nil {
+			rest := []object.Object{}
+			if len(args) > len(fn.Parameters) {
+				rest = append(rest, args[len(fn.Parameters):]...)
+			}
+			env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 		}
 
-		return evalStatements(fn.Body.Statements, env)
+		return unwrapReturnValue(evalStatements(fn.Body.Statements, env))
 	case object.BuiltInFunction:
 		// use function already defined with host lang(Go)
 		return fn(args...)
+	case *object.RecordType:
+		if len(args) != len(fn.Fields) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Fields), len(args))
+		}
+		return object.NewRecord(fn, args)
+	case *object.BoundMethod:
+		return applyFunction(fn.Method, append([]object.Object{fn.Receiver}, args...))
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
+// checkArity returns an error if fn cannot be called with n arguments
+func checkArity(fn *object.Function, n int) object.Object {
+	required := len(fn.Parameters) - len(fn.Defaults)
+	if n >= required && (n <= len(fn.Parameters) || fn.Rest != nil) {
+		return nil
+	}
+
+	return newError("wrong number of arguments: want=%s, got=%d",
+		object.FormatArity(required, len(fn.Defaults), fn.Rest != nil), n)
+}
+
 func evalArrayLiteral(node *ast.ArrayLiteral, env *object.Environment) object.Object {
 	elements := evalExpressions(node.Elements, env)
 	if len(elements) == 1 && object.IsError(elements[0]) {
@@ -291,12 +989,35 @@ func evalArrayLiteral(node *ast.ArrayLiteral, env *object.Environment) object.Ob
 	return &object.Array{Elements: elements}
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if object.IsError(value) {
+			return value
+		}
+		if value == nil {
+			value = object.NullValue()
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return object.String(out.String())
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING && index.Type() == object.INTEGER:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE && index.Type() == object.STRING:
+		return evalModuleIndexExpression(left, index)
+	case left.Type() == object.RECORD && index.Type() == object.STRING:
+		return evalRecordIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -314,13 +1035,140 @@ func evalArrayIndexExpression(left, index object.Object) object.Object {
 	return array.Elements[idx]
 }
 
+func evalStringIndexExpression(left, index object.Object) object.Object {
+	ch, ok := left.(object.String).At(int64(index.(object.Integer)))
+	if !ok {
+		return object.NullValue()
+	}
+
+	return ch
+}
+
+func evalModuleIndexExpression(left, index object.Object) object.Object {
+	module := left.(*object.Module)
+	val, ok := module.Exports[string(index.(object.String))]
+	if !ok {
+		return newError("%s has no export %s", module.Name, index.Inspect())
+	}
+
+	return val
+}
+
+func evalRecordIndexExpression(left, index object.Object) object.Object {
+	record := left.(*object.Record)
+	val, ok := record.Get(string(index.(object.String)))
+	if !ok {
+		return newError("%s has no field %s", record.RecordType.Name, index.Inspect())
+	}
+
+	return val
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if object.IsError(left) {
+		return left
+	}
+
+	bounds := [2]object.Object{object.NullValue(), object.NullValue()}
+	for i, bound := range []ast.Expression{node.Low, node.High} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if object.IsError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	return withPosition(node.Token.Pos, evalSlice(left, bounds[0], bounds[1]))
+}
+
+func evalSlice(left, low, high object.Object) object.Object {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case object.String:
+		length = int64(left.Len())
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	lo, err := sliceBound(low, 0)
+	if err != nil {
+		return err
+	}
+	hi, err := sliceBound(high, length)
+	if err != nil {
+		return err
+	}
+	lo, hi = object.SliceBounds(length, lo, hi)
+
+	if array, ok := left.(*object.Array); ok {
+		elements := make([]object.Object, hi-lo)
+		copy(elements, array.Elements[lo:hi])
+		return &object.Array{Elements: elements}
+	}
+
+	return left.(object.String).Slice(lo, hi)
+}
+
+// sliceBound returns the integer value of a slice bound, or def if the bound
+// was left out
+func sliceBound(bound object.Object, def int64) (int64, object.Object) {
+	switch bound := bound.(type) {
+	case *object.Null:
+		return def, nil
+	case object.Integer:
+		return int64(bound), nil
+	default:
+		return 0, newError("slice index must be INTEGER, got %s", bound.Type())
+	}
+}
+
+// evalSetIndex stores val at index in an array or hash. It returns an
+// error if that is not possible and nil otherwise.
+func evalSetIndex(left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+		array := left.(*object.Array)
+		idx := int64(index.(object.Integer))
+		if idx < 0 || idx >= int64(len(array.Elements)) {
+			return newError("index out of range: %d (length %d)", idx, len(array.Elements))
+		}
+		array.Elements[idx] = val
+		return nil
+	case left.Type() == object.HASH:
+		kk := evalHashKey(index)
+		if object.IsError(kk) {
+			return kk
+		}
+		left.(*object.Hash).Pairs[kk.(object.String)] = val
+		return nil
+	case left.Type() == object.RECORD && index.Type() == object.STRING:
+		record := left.(*object.Record)
+		if !record.Set(string(index.(object.String)), val) {
+			return newError("%s has no field %s", record.RecordType.Name, index.Inspect())
+		}
+		return nil
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	h := &object.Hash{Pairs: make(map[object.String]object.Object)}
 
 	for k, v := range node.Pairs {
-		kk := evalHashKey(Eval(k, env))
+		kObj := Eval(k, env)
+		if object.IsError(kObj) {
+			return kObj
+		}
+
+		kk := evalHashKey(kObj)
 		if object.IsError(kk) {
-			return kk
+			return withPosition(k.Pos(), kk)
 		}
 
 		key := kk.(object.String)
@@ -405,6 +1253,12 @@ func convertObjectToASTNode(obj object.Object) ast.Node {
 			Literal: fmt.Sprintf("%d", obj),
 		}
 		return &ast.IntegerLiteral{Token: t, Value: int64(obj)}
+	case object.Float:
+		t := token.Token{
+			Type:    token.FLOAT,
+			Literal: obj.Inspect(),
+		}
+		return &ast.FloatLiteral{Token: t, Value: float64(obj)}
 	case object.Boolean:
 		var t token.Token
 		if obj {
@@ -424,15 +1278,42 @@ func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case object.Boolean:
 		return bool(obj)
-	case object.Null:
+	case *object.Null:
 		return false
 	case object.Integer:
 		return int64(obj) != 0
+	case object.Float:
+		return float64(obj) != 0
 	default:
 		return true
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	_, ok := object.AsFloat(obj)
+	return ok
+}
+
 func newError(format string, a ...interface{}) object.Error {
 	return object.Error(fmt.Sprintf(format, a...))
 }
+
+// positionPrefix matches the position withPosition prefixes an error with
+var positionPrefix = regexp.MustCompile(`^(?:-|\d+:\d+|.*?:\d+:\d+): `)
+
+// errorMessage returns the message of err without its position, as the VM,
+// which doesn't track positions, reports it
+func errorMessage(err object.Error) object.String {
+	return object.String(positionPrefix.ReplaceAllString(string(err), ""))
+}
+
+// withPosition prefixes obj with pos if it is an error raised by the node
+// at pos. Errors propagated from child nodes must not be passed through it.
+func withPosition(pos token.Position, obj object.Object) object.Object {
+	err, ok := obj.(object.Error)
+	if !ok {
+		return obj
+	}
+
+	return object.Error(fmt.Sprintf("%s: %s", pos, err))
+}
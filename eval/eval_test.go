@@ -1,11 +1,14 @@
 package eval
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
 
 	"github.com/dikaeinstein/monkey/lexer"
 	"github.com/dikaeinstein/monkey/object"
 	"github.com/dikaeinstein/monkey/parser"
+	"github.com/dikaeinstein/monkey/test"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -28,6 +31,15 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"1 + 6 & 3", 3},
+		{"10 - 2 * 3 % 4", 8},
 	}
 
 	for _, tC := range testCases {
@@ -36,6 +48,71 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"0.5 + 0.25", 0.75},
+		{"1.5 * 2", 3},
+		{"2 * 1.5", 3},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"10 - 0.5", 9.5},
+		{"1e3 + 1", 1001},
+		{"float(3)", 3},
+		{`float("2.25")`, 2.25},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		testFloatObject(t, evaluated, tC.expected)
+	}
+}
+
+func TestNumericComparisons(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 != 1.5", true},
+		{"0.5 > 0.25", true},
+		{"0.5 < 0.25", false},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		testBooleanObject(t, evaluated, tC.expected)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "1.5"},
+		{"2.0", "2.0"},
+		{"3 * 1.0", "3.0"},
+		{"1e21", "1e+21"},
+		{"int(2.9)", "2"},
+		{`int("42")`, "42"},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		if evaluated.Inspect() != tC.expected {
+			t.Errorf("wrong Inspect() for %q. want=%q, got=%q",
+				tC.input, tC.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	evaluated := testEval(t, input)
@@ -48,6 +125,30 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringInterpolation(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`let price = 2.5; let qty = 4; "total: ${price * qty}"`, "total: 10.0"},
+		{`"${1} + ${true} = ${[1, 2]}"`, "1 + true = [1, 2]"},
+		{`let name = "monkey"; "hi ${name}, ${"nested ${name}"}!"`, "hi monkey, nested monkey!"},
+		{`"${ {"a": 1}["a"] }"`, "1"},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		str, ok := evaluated.(object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+		if string(str) != tC.expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", tC.expected, str)
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 	evaluated := testEval(t, input)
@@ -84,6 +185,46 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1 <= 1", true},
+		{"1 <= 0", false},
+		{"2 >= 3", false},
+		{"3 >= 3", true},
+		{"1.5 <= 2", true},
+		{"2 >= 2.5", false},
+		{`"a" < "b"`, true},
+		{`"abc" > "abd"`, false},
+		{`"b" >= "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		testBooleanObject(t, evaluated, tC.expected)
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"true && 5", true},
+		{"false || if (false) { 1 }", false},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
+		{"let x = 1; false && (x = 2); x == 1", true},
+		{"let x = 1; true || (x = 2); x == 1", true},
+		{"let x = 1; true && (x = 2); x == 2", true},
 	}
 
 	for _, tC := range testCases {
@@ -122,6 +263,10 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (false) { 10 } else if (true) { 20 } else { 30 }", 20},
+		{"if (false) { 10 } else if (false) { 20 } else { 30 }", 30},
+		{"if (false) { 10 } else if (1 > 2) { 20 }", nil},
+		{"let x = 3; if (x == 1) { 10 } else if (x == 2) { 20 } else if (x == 3) { 30 } else { 40 }", 30},
 	}
 
 	for _, tC := range testCases {
@@ -135,6 +280,80 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestMatchExpressions(t *testing.T) {
+	describe := `
+	let describe = fn(v) {
+		match (v) {
+			0 => "zero",
+			-1 => "minus one",
+			"hi" => "greeting",
+			true => "yes",
+			[] => "empty",
+			[x] => "one " + x,
+			[1, [a, _]] if a > 2 => "nested",
+			[_, _] => "pair",
+			{"type": "point", "x": x, "y": y} => x + y,
+			{} => "hash",
+			2.5 => "float",
+			_ => "other",
+		}
+	};
+	`
+
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{describe + `describe(0)`, "zero"},
+		{describe + `describe(0.0)`, "zero"},
+		{describe + `describe(-1)`, "minus one"},
+		{describe + `describe("hi")`, "greeting"},
+		{describe + `describe(true)`, "yes"},
+		{describe + `describe(false)`, "other"},
+		{describe + `describe([])`, "empty"},
+		{describe + `describe(["a"])`, "one a"},
+		{describe + `describe([1, [3, 4]])`, "nested"},
+		{describe + `describe([1, [2, 4]])`, "pair"},
+		{describe + `describe([1, 2, 3])`, "other"},
+		{describe + `describe({"type": "point", "x": 1, "y": 2})`, 3},
+		{describe + `describe({"type": "point", "x": 1})`, "hash"},
+		{describe + `describe(2.5)`, "float"},
+		{describe + `describe(fn() {})`, "other"},
+		{"match (5) { 1 => 2 }", nil},
+		{"match (5) { }", nil},
+		{"match (5) { n if n > 10 => 1, n => n * 2 }", 10},
+		{"let n = 1; match ([2]) { [n] => n }; n", 1},
+		{"let x = 9; match (1) { x => x }; x", 9},
+		{"let x = 9; match (1) { x => x }", 1},
+		{"let x = 9; match ([1, 2]) { [x, 3] => 0, _ => x }", 9},
+		{"let f = fn(x) { match (1) { x => 0 }; x }; f(5)", 5},
+		{"let f = fn(x) { match ([1, 2]) { [x, 3] => 0, [_, x] => x } + x }; f(5)", 7},
+		{"let f = fn(x) { match (x) { 1 => if (true) { return 10 } }; 20 }; f(1) + f(2)", 30},
+		{"match (match (1) { 1 => [2] }) { [x] => match (x) { 2 => 3 } }", 3},
+		{"match ([1, 2]) { [a, b] if match (b) { 2 => false, _ => true } => 0, [a, _] => a }", 1},
+		{"let n = 0; for (x in [[1], [2, 3], 4]) { n += match (x) { [a] => a, [a, b] => a * b, _ => 0 } } n", 7},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		switch expected := tC.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if string(str) != expected {
+				t.Errorf("String has wrong value. want=%q, got=%q", expected, str)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -144,6 +363,9 @@ func TestReturnStatements(t *testing.T) {
 		{"return 10; 9;", 10},
 		{"return 2 * 5; 9;", 10},
 		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+		{"let f = fn() { while (true) { return 7; } return 1; }; f();", 7},
+		{"let f = fn(x) { x }; f(1); 3", 3},
 	}
 
 	for _, tC := range testCases {
@@ -159,27 +381,27 @@ func TestErrorHandling(t *testing.T) {
 	}{
 		{
 			"5 + true;",
-			"type mismatch: INTEGER + BOOLEAN",
+			"1:3: type mismatch: INTEGER + BOOLEAN",
 		},
 		{
 			"5 + true; 5;",
-			"type mismatch: INTEGER + BOOLEAN",
+			"1:3: type mismatch: INTEGER + BOOLEAN",
 		},
 		{
 			"-true",
-			"unknown operator: -BOOLEAN",
+			"1:1: unknown operator: -BOOLEAN",
 		},
 		{
 			"true + false;",
-			"unknown operator: BOOLEAN + BOOLEAN",
+			"1:6: unknown operator: BOOLEAN + BOOLEAN",
 		},
 		{
 			"5; true + false; 5",
-			"unknown operator: BOOLEAN + BOOLEAN",
+			"1:9: unknown operator: BOOLEAN + BOOLEAN",
 		},
 		{
 			"if (10 > 1) { true + false; }",
-			"unknown operator: BOOLEAN + BOOLEAN",
+			"1:20: unknown operator: BOOLEAN + BOOLEAN",
 		},
 		{
 			`
@@ -190,19 +412,151 @@ func TestErrorHandling(t *testing.T) {
 		return 1;
 	}
 	`,
-			"unknown operator: BOOLEAN + BOOLEAN",
+			"4:16: unknown operator: BOOLEAN + BOOLEAN",
 		},
 		{
 			"foobar",
-			"identifier not found: foobar",
+			"1:1: identifier not found: foobar",
 		},
 		{
 			`"Hello" - "World"`,
-			"unknown operator: STRING - STRING",
+			"1:9: unknown operator: STRING - STRING",
 		},
 		{
 			`{"name": "Monkey"}[fn(x) { x }];`,
-			"unusable as hash key: FUNCTION",
+			"1:19: unusable as hash key: FUNCTION",
+		},
+		{
+			"1 / 0",
+			"1:3: division by zero",
+		},
+		{
+			"5 % (1 - 1)",
+			"1:3: division by zero",
+		},
+		{
+			"1 << -1",
+			"1:3: negative shift count: -1",
+		},
+		{
+			"1.5 & 1",
+			"1:5: unknown operator: FLOAT & INTEGER",
+		},
+		{
+			"y = 1",
+			"1:1: identifier not found: y",
+		},
+		{
+			"type P { x }; P(1) < P(2)",
+			"1:20: unknown operator: RECORD < RECORD",
+		},
+		{
+			"match (1) { y => y }; y",
+			"1:23: identifier not found: y",
+		},
+		{
+			`let s = "a"; s -= 1`,
+			"1:16: type mismatch: STRING - INTEGER",
+		},
+		{
+			"let a = [1]; a[5] = 2",
+			"1:15: index out of range: 5 (length 1)",
+		},
+		{
+			`"abc"[0] = "x"`,
+			"1:6: index assignment not supported: STRING",
+		},
+		{
+			"let h = {}; h[fn(x) { x }] = 1",
+			"1:14: unusable as hash key: FUNCTION",
+		},
+		{
+			"for (x in 5) { x }",
+			"1:11: cannot iterate over INTEGER",
+		},
+		{
+			"while (true) { 1 + true; }",
+			"1:18: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"fn(a, b) { a }(1)",
+			"1:15: wrong number of arguments: want=2, got=1",
+		},
+		{
+			"fn(a, b = 1) { a }(1, 2, 3)",
+			"1:19: wrong number of arguments: want=1..2, got=3",
+		},
+		{
+			"let f = fn(a, ...b) { a }; f()",
+			"1:29: wrong number of arguments: want=1.., got=0",
+		},
+		{
+			"fn(a = 1 + true) { a }()",
+			"1:10: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"let [a, b] = 1",
+			"1:14: cannot destructure INTEGER as ARRAY",
+		},
+		{
+			"let [a, b] = [1, 2, 3]",
+			"1:14: wrong number of elements to destructure: want=2, got=3",
+		},
+		{
+			"let [a, b, ...c] = [1]",
+			"1:20: not enough elements to destructure: want at least 2, got 1",
+		},
+		{
+			"let {a} = [1]",
+			"1:11: cannot destructure ARRAY as HASH",
+		},
+		{
+			`let {a, b} = {"a": 1}`,
+			"1:14: cannot destructure missing key: b",
+		},
+		{
+			"5[1:]",
+			"1:2: slice operator not supported: INTEGER",
+		},
+		{
+			`let f = fn() { throw "boom" }; f()`,
+			"1:16: uncaught exception: boom",
+		},
+		{
+			"try { throw 1 } finally { }",
+			"1:7: uncaught exception: 1",
+		},
+		{
+			"try { throw 1 } catch (e) { e + true }",
+			"1:31: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`[1, 2]["a":]`,
+			"1:7: slice index must be INTEGER, got STRING",
+		},
+		{
+			"type P { x }; P(1).y",
+			"1:19: P has no field y",
+		},
+		{
+			"type P { x, y }; P(1)",
+			"1:19: wrong number of arguments: want=2, got=1",
+		},
+		{
+			"type P { x, fn m(self, a) { a } }; P(1).m()",
+			"1:42: wrong number of arguments: want=2, got=1",
+		},
+		{
+			"let a = 1; a.x",
+			"1:13: index operator not supported: INTEGER",
+		},
+		{
+			"let a = [1]; a.x = 2",
+			"1:15: index assignment not supported: ARRAY",
+		},
+		{
+			"type P { x }; P(1).y = 2",
+			"1:19: P has no field y",
 		},
 	}
 
@@ -220,6 +574,100 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestLoops(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; } i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 2) { break; } } i", 3},
+		{"let i = 0; while (i < 3) { i += 1 }; i", 3},
+		{"let n = 0; for (let i = 0; i < 3; i += 1) { n += i }; n", 3},
+		{"let n = 0; for (x in [1, 2]) { n += x }; n", 3},
+		{"let n = 0; for (let i = 0; i < 4; let i = i + 1) { let n = n + i; } n", 6},
+		{"let n = 0; for (let i = 0; i < 6; let i = i + 1) { if (i < 3) { continue; } let n = n + i; } n", 12},
+		{"let n = 0; for (x in [1, 2, 3]) { let n = n + x; } n", 6},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } let n = n + x; } n", 3},
+		{`let n = 0; for (c in "héllo") { let n = n + 1; } n`, 5},
+		{`let s = ""; for (k in {"b": 2, "a": 1}) { let s = s + k; } len(s)`, 2},
+		{"let n = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y > 10) { break; } let n = n + x * y; } } n", 30},
+		{"let sum = fn(arr) { let n = 0; for (x in arr) { let n = n + x; } n }; sum([4, 5])", 9},
+		{"let i = 0; while (i < 3000) { i = i + 1; let a = [1, 2, if (true) { continue }]; }; i", 3000},
+		{"let f = fn() { let i = 0; while (i < 3000) { i = i + 1; let a = [1, 2, if (true) { continue }]; }; i }; f()", 3000},
+		{"let n = 0; for (let i = 0; i < 3000; i += 1) { n += [1, if (true) { continue }][0] }; n", 0},
+		{"let n = 0; for (x in [1, 2, 3]) { n += len([x, if (x == 2) { break }]) }; n", 2},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		testIntegerObject(t, evaluated, tC.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 1; x -= 2; x", -1},
+		{"let x = 3; x *= 2; x", 6},
+		{"let x = 7; x /= 2; x", 3},
+		{"let x = 1; let y = (x = 7); x + y", 14},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let i = 0; while (i < 5) { i += 1; } i", 5},
+		{"let n = 0; for (let i = 0; i < 4; i += 1) { n += i; } n", 6},
+		{"let x = 1; let f = fn() { x = 10; }; f(); x", 10},
+		{"let f = fn() { let x = 1; let g = fn() { x }; x = 2; g() }; f()", 2},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()", 3},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		testIntegerObject(t, evaluated, tC.expected)
+	}
+}
+
+func TestIndexAssignment(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; a[2] += 10; a[2]", 13},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 9; a[0]", 9},
+		{"let a = [1, 2, 3]; a[0] = a[1] = 7; a[0] + a[1]", 14},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, 2},
+		{`let h = {}; h["b"] = 3; h["b"] *= 2; h["b"]`, 6},
+		{`let h = {}; h[1] = 4; h[1]`, 4},
+		{"let a = [0, 0, 0]; for (let i = 0; i < 3; i += 1) { a[i] = i * i; } a[2]", 4},
+		{"let a = [[1], [2]]; a[1][0] = 5; a[1][0]", 5},
+		{`let h = {"a": 1}; h.a = 2; h["a"]`, 2},
+		{`let h = {}; h.b = 3; h.b *= 2; h.b`, 6},
+		{`let h = {"db": {}}; h.db.port = 80; h["db"]["port"]`, 80},
+		{"type P { x, y }; let p = P(1, 2); p.x += 10; p.x + p.y", 13},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		testIntegerObject(t, evaluated, tC.expected)
+	}
+}
+
+func TestForInHashOrder(t *testing.T) {
+	input := `let s = ""; for (k in {"b": 2, "c": 3, "a": 1}) { let s = s + k; } s`
+
+	evaluated := testEval(t, input)
+	str, ok := evaluated.(object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str != "abc" {
+		t.Errorf("String has wrong value. got=%q", str)
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -236,6 +684,163 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, ...rest] = [1, 2, 3]; rest", []int64{2, 3}},
+		{"let [...rest] = [1, 2]; rest", []int64{1, 2}},
+		{"let [a, b, ...rest] = [1, 2]; rest", []int64{}},
+		{"let [] = []; 1", 1},
+		{`let {name, age} = {"name": "x", "age": 3, "other": 4}; age`, 3},
+		{`let {} = {"a": 1}; 1`, 1},
+		{"let f = fn(p) { let [x, ...xs] = p; let {k} = {\"k\": x}; k + len(xs) }; f([5, 6, 7])", 7},
+		{"let a = 1; let [a, b] = [a + 1, a + 2]; a + b", 5},
+		{"let [a, b] = [1, 2]; let [a, b] = [b, a]; a * 10 + b", 21},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		switch expected := tC.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d",
+					len(expected), len(array.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, array.Elements[i], el)
+			}
+		}
+	}
+}
+
+func TestTryStatements(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let r = 0; try { throw 5 } catch (e) { r = e }; r", 5},
+		{`let f = fn() { throw "bad" }; let r = ""; try { f(); r = "ok" } catch (e) { r = e }; r`, "bad"},
+		{"let g = fn(x) { if (x == 0) { throw x }; g(x - 1) + 1 }; let r = -1; try { g(3) } catch (e) { r = e }; r", 0},
+		{`let r = 0; try { throw {"code": 7} } catch (e) { r = e["code"] }; r`, 7},
+		{"let n = 0; try { n = n + 1 } finally { n = n + 10 }; n", 11},
+		{"let n = 0; try { throw 1 } catch (e) { n = n + e } finally { n = n * 10 }; n", 10},
+		{"let n = 0; try { try { throw 2 } finally { n = 1 } } catch (e) { n = n + e }; n", 3},
+		{"let n = 0; try { try { throw 1 } catch (e) { throw e + 1 } finally { n = 10 } } catch (e) { n = n + e }; n", 12},
+		{"let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; f() + n", 6},
+		{"let n = 0; let f = fn() { try { throw 1 } catch (e) { return e } finally { n = 2 } }; f() + n", 3},
+		{"let f = fn() { try { throw 1 } catch (e) { return e + 1 } }; 10 + f()", 12},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let n = 0; while (true) { try { break } finally { n = n + 1 } } n", 1},
+		{"let n = 0; let i = 0; while (i < 3) { i = i + 1; try { continue } finally { n = n + i } } n", 6},
+		{"let n = 0; for (i in [1, 2, 3]) { try { throw i } catch (e) { n = n + e } } n", 6},
+		{"let f = fn() { try { 1 } catch (e) { 2 } }; f(); let r = 0; try { throw 3 } catch (e) { r = e }; r", 3},
+		{"let r = 0; try { try { throw 1 } catch (e) { r = 10 } } catch (e) { r = 20 }; r", 10},
+		{`let r = ""; try { 1 / 0 } catch (e) { r = e }; r`, "division by zero"},
+		{`let r = ""; try { [1] + 1 } catch (e) { r = e }; r`, "type mismatch: ARRAY + INTEGER"},
+		{`try { throw "boom" } catch (e) { "caught " + e }`, "caught boom"},
+		{"try { 5 } catch (e) { 0 }", 5},
+		{"let f = fn() { try { throw 1 } catch (e) { e } }; f() + 1", 2},
+		{"let f = fn() { try { 1 } finally { 2 } }; f()", 1},
+		{"let n = 0; let f = fn() { try { throw 1 } catch (e) { e + 1 } finally { n = 10 } }; f() + n", 12},
+		{"if (true) { try { 3 } catch (e) { 4 } }", 3},
+		{"let e = 1; try { throw 2 } catch (e) { e }; e", 1},
+		{"let f = fn(e) { try { throw 2 } catch (e) { e }; e }; f(1)", 1},
+		{"let i = 0; while (i < 3000) { i += 1; try { i } finally { continue } } i", 3000},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		switch expected := tC.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if string(str) != expected {
+				t.Errorf("String has wrong value. want=%q, got=%q", expected, str)
+			}
+		}
+	}
+}
+
+func TestRecordTypes(t *testing.T) {
+	point := `type Point {
+		x, y,
+		fn move(self, dx, dy) { Point(self.x + dx, self.y + dy) }
+		fn norm(self) { self.x * self.x + self.y * self.y }
+		fn scaled(self, k = 2) { self.move(self.x * (k - 1), self.y * (k - 1)) }
+	}
+	let p = Point(1, 2);
+	`
+
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{point + "p.x", 1},
+		{point + "p.y", 2},
+		{point + `p["x"] + p["y"]`, 3},
+		{point + "p.move(2, 3).y", 5},
+		{point + "p.norm()", 5},
+		{point + "p.scaled().norm()", 20},
+		{point + "let m = p.move; m(1, 1).x", 2},
+		{point + "p", "Point{x: 1, y: 2}"},
+		{point + "Point", "type(Point)"},
+		{point + "p.norm", "method(Point.norm)"},
+		{"let f = fn(n) { type Box { v, fn get(self) { self.v + n } } Box(1) }; f(10).get()", 11},
+		{"type Counter { n, fn next(self) { Counter(self.n + 1) } }; Counter(0).next().next().n", 2},
+		{`type Pair { a, b }; Pair("x", [1]).b`, "[1]"},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		switch expected := tC.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("wrong Inspect(). want=%q, got=%q", expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestPipelineExpressions(t *testing.T) {
+	helpers := `
+	let map = fn(xs, f) { let out = []; for (x in xs) { out = push(out, f(x)) } out };
+	let sum = fn(xs) { let s = 0; for (x in xs) { s += x } s };
+	`
+
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{helpers + "[1, 2, 3] |> map(fn(x) { x * 2 }) |> sum()", 12},
+		{helpers + "[1, 2] |> map(fn(x) { x + 1 }) |> len()", 2},
+		{"1 + 2 |> fn(a, b) { a * b }(4)", 12},
+		{`"abc" |> len()`, 3},
+		{"type P { x, fn add(self, n) { self.x + n } }; 5 |> P(1).add()", 6},
+	}
+
+	for _, tC := range testCases {
+		testIntegerObject(t, testEval(t, tC.input), tC.expected)
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 	evaluated := testEval(t, input)
@@ -267,6 +872,17 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a = 1, b = a + 1) { a * 10 + b }; f()", 12},
+		{"let f = fn(a = 1, b = a + 1) { a * 10 + b }; f(5)", 56},
+		{"let f = fn(a = 1, b = a + 1) { a * 10 + b }; f(5, 0)", 50},
+		{"let f = fn(...xs) { len(xs) }; f()", 0},
+		{"let f = fn(...xs) { xs[2] }; f(1, 2, 3)", 3},
+		{"let f = fn(a, b = 2, ...xs) { a + b + len(xs) }; f(1)", 3},
+		{"let f = fn(a, b = 2, ...xs) { xs[1] }; f(1, 3, 4, 5)", 5},
+		{"let f = fn(a, x = fn() { a }) { x() }; f(7)", 7},
+		{"let f = fn(n, acc = 1) { if (n < 2) { acc } else { f(n - 1, acc * n) } }; f(5)", 120},
 	}
 
 	for _, tC := range testCases {
@@ -302,8 +918,11 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len("héllo, 世界")`, 9},
+		{`len(1)`, "1:4: argument to `len` not supported, got INTEGER"},
+		{`int("abc")`, "1:4: could not convert \"abc\" to INTEGER"},
+		{`float(true)`, "1:6: argument to `float` not supported, got BOOLEAN"},
+		{`len("one", "two")`, "1:4: wrong number of arguments. got=2, want=1"},
 		{`len([1,2,3])`, 3},
 		{`len([])`, 0},
 		{`first([1, "2", "a"])`, 1},
@@ -416,6 +1035,85 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"monkey"[0]`, "m"},
+		{`"héllo"[1]`, "é"},
+		{`let s = "世界"; s[1]`, "界"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-1]`, nil},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		expected, ok := tC.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+		if string(str) != expected {
+			t.Errorf("String has wrong value. want=%q, got=%q", expected, str)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4][1:3]", []int64{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int64{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int64{3, 4}},
+		{"[1, 2, 3, 4][-3:-1]", []int64{2, 3}},
+		{"[1, 2, 3][:]", []int64{1, 2, 3}},
+		{"[1, 2, 3][2:1]", []int64{}},
+		{"[1, 2, 3][-10:10]", []int64{1, 2, 3}},
+		{"let a = [1, 2]; let b = a[:]; b[0] = 5; a", []int64{1, 2}},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[-2:]`, "lo"},
+		{`"世界"[:1]`, "世"},
+		{`"abc"[5:]`, ""},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, tC.input)
+		switch expected := tC.expected.(type) {
+		case string:
+			str, ok := evaluated.(object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if string(str) != expected {
+				t.Errorf("String has wrong value. want=%q, got=%q", expected, str)
+			}
+		case []int64:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d",
+					len(expected), len(array.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, array.Elements[i], el)
+			}
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -486,6 +1184,18 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{"foo": 5}.foo`,
+			5,
+		},
+		{
+			`{"foo": 5}.bar`,
+			nil,
+		},
+		{
+			`let config = {"db": {"port": 5432}}; config.db.port`,
+			5432,
+		},
 	}
 
 	for _, tC := range testCases {
@@ -613,6 +1323,115 @@ func testNullObject(t *testing.T, obj object.Object) {
 	}
 }
 
+// modules are the files imported by the module tests
+var modules = map[string]string{
+	"lib/math.monkey": `
+import "util.monkey" as util
+let square = fn(x) { x * x }
+export let sumOfSquares = fn(a, b) { square(a) + square(b) }
+export let [first, ...others] = [1, 2, 3]
+export let read = fn() { util["state"][0] }
+export type Vec { x, y, fn dot(self, o) { self.x * o.x + self.y * o.y } }
+`,
+	"lib/util.monkey": `export let state = [0]`,
+	"a.monkey":        `import "b.monkey" as b`,
+	"b.monkey":        `import "a.monkey" as a`,
+	"syntax.monkey":   `let = 1`,
+	"runtime.monkey":  "let x = 1;\nx + true",
+}
+
+func TestImportStatements(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{`import "%s/lib/math.monkey" as m; m["sumOfSquares"](2, 3)`, 13},
+		{`import "%s/lib/math.monkey" as m; m["first"] * 10 + len(m["others"])`, 12},
+		{`import "%[1]s/lib/math.monkey" as m; import "%[1]s/lib/util.monkey" as u;
+		u["state"][0] = 7; m["read"]()`, 7},
+		{`let square = 2; import "%s/lib/math.monkey" as m; square`, 2},
+		{`import "%s/lib/math.monkey" as m; m.Vec(1, 2).dot(m.Vec(3, 4)) + m.sumOfSquares(1, 1)`, 13},
+	}
+
+	for _, tC := range testCases {
+		testIntegerObject(t, testEval(t, fmt.Sprintf(tC.input, dir)), tC.expected)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			`import "%s/missing.monkey" as m`,
+			"1:8: cannot import %[1]s/missing.monkey: open %[1]s/missing.monkey: no such file or directory",
+		},
+		{
+			`import "%s/syntax.monkey" as m`,
+			"1:8: cannot import %[1]s/syntax.monkey: %[1]s/syntax.monkey:1:5: expected next token to be IDENT, got = instead",
+		},
+		{
+			`import "%s/runtime.monkey" as m`,
+			"%s/runtime.monkey:2:3: type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			`import "%s/a.monkey" as a`,
+			"%[1]s/b.monkey:1:8: import cycle: %[1]s/a.monkey -> %[1]s/b.monkey -> %[1]s/a.monkey",
+		},
+		{
+			"import \"%s/lib/math.monkey\" as m\nm[\"square\"]",
+			"2:2: %s/lib/math.monkey has no export square",
+		},
+	}
+
+	for _, tC := range testCases {
+		evaluated := testEval(t, fmt.Sprintf(tC.input, dir))
+		errVal, ok := evaluated.(object.Error)
+		if !ok {
+			t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		}
+
+		expected := fmt.Sprintf(tC.expectedMessage, dir)
+		if string(errVal) != expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", expected, errVal)
+		}
+	}
+}
+
+func TestImportCycleFromFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := parser.ParseFile(filepath.Join(dir, "a.monkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluated := Eval(program, object.NewEnvironment())
+	errVal, ok := evaluated.(object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := fmt.Sprintf("%[1]s/b.monkey:1:8: import cycle: %[1]s/a.monkey -> %[1]s/b.monkey -> %[1]s/a.monkey", dir)
+	if string(errVal) != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errVal)
+	}
+}
+
 func testEval(t *testing.T, input string) object.Object {
 	t.Helper()
 
@@ -640,6 +1459,19 @@ func checkParserErrors(t *testing.T, p *parser.Parser) {
 	t.FailNow()
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) {
+	t.Helper()
+
+	result, ok := obj.(object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return
+	}
+	if float64(result) != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result, expected)
+	}
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) {
 	t.Helper()
 
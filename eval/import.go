@@ -0,0 +1,78 @@
+package eval
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/dikaeinstein/monkey/ast"
+	"github.com/dikaeinstein/monkey/object"
+	"github.com/dikaeinstein/monkey/parser"
+)
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := importModule(node, env)
+	if object.IsError(module) {
+		return module
+	}
+
+	env.Set(node.Name.Value, module)
+	return nil
+}
+
+// importModule returns the module imported by node, evaluating it in an
+// environment of its own the first time it is imported
+func importModule(node *ast.ImportStatement, env *object.Environment) object.Object {
+	path := node.File()
+	modules := env.Modules()
+
+	if module, ok := modules.Loaded[path]; ok {
+		return module
+	}
+
+	if len(modules.Loading) == 0 && node.Token.Pos.Filename != "" {
+		// the file importing node is the root of the imports, so a cycle
+		// back to it is reported where it closes
+		modules.Loading = []string{filepath.Clean(node.Token.Pos.Filename)}
+		defer func() { modules.Loading = nil }()
+	}
+
+	for i, loading := range modules.Loading {
+		if loading == path {
+			cycle := append(modules.Loading[i:len(modules.Loading):len(modules.Loading)], path)
+			return withPosition(node.Path.Pos(),
+				newError("import cycle: %s", strings.Join(cycle, " -> ")))
+		}
+	}
+
+	program, err := parser.ParseFile(path)
+	if err != nil {
+		return withPosition(node.Path.Pos(), newError("cannot import %s: %s", path, err))
+	}
+
+	macroEnv := object.NewEnvironment()
+	DefineMacros(program, macroEnv)
+	expanded := ExpandMacros(program, macroEnv)
+
+	modules.Loading = append(modules.Loading, path)
+	defer func() { modules.Loading = modules.Loading[:len(modules.Loading)-1] }()
+
+	moduleEnv := object.NewModuleEnvironment(env)
+	result := Eval(expanded, moduleEnv)
+	if object.IsError(result) {
+		return result
+	}
+
+	module := &object.Module{Name: path, Exports: make(map[string]object.Object)}
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		for _, name := range export.Names() {
+			module.Exports[name.Value], _ = moduleEnv.Get(name.Value)
+		}
+	}
+	modules.Loaded[path] = module
+
+	return module
+}
@@ -1,46 +1,177 @@
 package lexer
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/dikaeinstein/monkey/token"
 )
 
-// Lexer transforms the input(source code) to tokens
+// Error describes a lexical error such as an unterminated comment
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// Lexer transforms the input(source code) to tokens. The input is read
+// incrementally, so only the current char and a few chars of lookahead
+// are held in memory.
 type Lexer struct {
-	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	r            io.RuneReader
+	ahead        []char // chars read from r but not yet consumed
+	eof          bool   // whether r has been exhausted
+	position     int    // current byte offset in input (points to current char)
+	readPosition int    // current reading byte offset in input (after current char)
+	ch           rune   // current char under examination
+	line         int    // line of the current char
+	column       int    // column of the current char, counted in runes
+	filename     string // name of the file being read, if any
+
+	// text collects the chars consumed since startCapture was called
+	text      strings.Builder
+	capturing bool
+
+	// interpolations holds, for each ${...} expression being lexed, the
+	// number of braces opened inside it that are still unclosed
+	interpolations []int
+
+	keepComments bool
+	comments     []token.Comment // comments read since the last token
+	errors       []Error
+}
+
+// char is a rune read from the input along with its size in bytes
+type char struct {
+	ch   rune
+	size int
 }
 
 // New initializes and returns a `ready-to-use` Lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewReader(strings.NewReader(input))
+}
+
+// NewReader returns a Lexer that reads its input from r as tokens are
+// requested. If r is not an io.RuneReader it is wrapped in a bufio.Reader.
+func NewReader(r io.Reader) *Lexer {
+	rr, ok := r.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(r)
+	}
+
+	l := &Lexer{r: rr, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewFile returns a Lexer that reads the file named filename from r. The
+// filename is recorded in the positions of the tokens.
+func NewFile(filename string, r io.Reader) *Lexer {
+	l := NewReader(r)
+	l.filename = filename
+	return l
+}
+
+// NewWithComments returns a Lexer that attaches the comments preceding
+// each token to its Comments field instead of discarding them
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.keepComments = true
+	return l
+}
+
+// Errors returns the lexical errors found so far
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
 // NextToken returns the next transformed token
-//gocyclo:ignore
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhiteSpace()
 
+	pos := l.pos()
+	tok := l.readToken()
+
+	tok.Pos = pos
+	if tok.Type == token.EOF {
+		tok.End = pos
+	} else {
+		tok.End = l.pos()
+	}
+
+	if len(l.comments) > 0 {
+		tok.Comments = &token.CommentGroup{List: l.comments}
+		l.comments = nil
+	}
+
+	return tok
+}
+
+// Iterator steps through the tokens of a Lexer
+//
+//	it := l.Iter()
+//	for it.Next() {
+//		tok := it.Token()
+//		...
+//	}
+type Iterator struct {
+	l   *Lexer
+	tok token.Token
+}
+
+// Iter returns an Iterator over the remaining tokens of l
+func (l *Lexer) Iter() *Iterator {
+	return &Iterator{l: l}
+}
+
+// Next advances the iterator to the next token. It returns false once the
+// EOF token has been reached.
+func (it *Iterator) Next() bool {
+	if it.tok.Type == token.EOF {
+		return false
+	}
+
+	it.tok = it.l.NextToken()
+	return it.tok.Type != token.EOF
+}
+
+// Token returns the token the iterator is positioned at
+func (it *Iterator) Token() token.Token {
+	return it.tok
+}
+
+//gocyclo:ignore
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
 			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: string(ch) + string(l.ch)}
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.newOperator(token.PLUS, '=', token.PlusAssign)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.newOperator(token.MINUS, '=', token.MinusAssign)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		tok = l.newOperator(token.ASTERISK, '=', token.AsteriskAssign)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -50,11 +181,31 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.newOperator(token.SLASH, '=', token.SlashAssign)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
+	case '&':
+		tok = l.newOperator(token.AMPERSAND, '&', token.AND)
+	case '|':
+		if l.peekChar() == '>' {
+			tok = l.newOperator(token.PIPE, '>', token.PIPELINE)
+		} else {
+			tok = l.newOperator(token.PIPE, '|', token.OR)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '<' {
+			tok = l.newOperator(token.LT, '<', token.ShiftLeft)
+		} else {
+			tok = l.newOperator(token.LT, '=', token.LtEQ)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '>' {
+			tok = l.newOperator(token.GT, '>', token.ShiftRight)
+		} else {
+			tok = l.newOperator(token.GT, '=', token.GtEQ)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case '(':
@@ -64,18 +215,40 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1] == 0 {
+				// end of a ${...} expression, resume reading the string
+				l.interpolations = l.interpolations[:n-1]
+				tok.Literal, tok.Type = l.readString()
+				break
+			}
+			l.interpolations[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '"':
+		tok.Literal, tok.Type = l.readString()
+	case '`':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readRawString()
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peek(2).ch == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -87,8 +260,7 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 		if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		}
 
@@ -100,64 +272,341 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
 	}
 
+	if l.capturing && l.readPosition > l.position {
+		l.text.WriteRune(l.ch)
+	}
+
+	next := l.peek(1)
+	if len(l.ahead) > 0 {
+		l.ahead = l.ahead[1:]
+	}
+
+	l.ch = next.ch
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += next.size
+	l.column++
+}
+
+// peek returns the char n chars after the current one, reading it from the
+// input if needed. It returns a zero char at the end of the input.
+func (l *Lexer) peek(n int) char {
+	for len(l.ahead) < n && !l.eof {
+		ch, size, err := l.r.ReadRune()
+		if err != nil {
+			if err != io.EOF {
+				l.errorf(l.pos(), "read error: %s", err)
+			}
+			l.eof = true
+			break
+		}
+
+		l.ahead = append(l.ahead, char{ch: ch, size: size})
+	}
+
+	if len(l.ahead) < n {
+		return char{}
+	}
+	return l.ahead[n-1]
+}
+
+// startCapture starts collecting the chars consumed from the current one on
+func (l *Lexer) startCapture() {
+	l.text.Reset()
+	l.capturing = true
+}
+
+// captured stops collecting chars and returns the text consumed since
+// startCapture, excluding the current char
+func (l *Lexer) captured() string {
+	l.capturing = false
+	return l.text.String()
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
+// pos returns the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
 	}
-	return l.input[l.readPosition]
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peek(1).ch
 }
 
 func (l *Lexer) readIdentifier() string {
-	position := l.position
+	l.startCapture()
 	for isLetter(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	return l.captured()
+}
+
+// readNumber reads an integer or a float literal. Integers may have a
+// 0x, 0o or 0b base prefix and use '_' as a digit separator. A float has a
+// fractional part, an exponent or both: 1.5, 1e-3, 2.5E10.
+func (l *Lexer) readNumber() (string, token.Type) {
+	l.startCapture()
+	tokenType := token.INT
+
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		l.readChar()
+		l.readChar()
+		// invalid digits for the base are left for the parser to report
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return l.captured(), tokenType
+	}
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if next == '+' || next == '-' {
+			next = l.peek(2).ch
+		}
+
+		if isDigit(next) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.captured(), tokenType
 }
 
-func (l *Lexer) readNumber() string {
-	position := l.position
-	for isDigit(l.ch) {
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads a double-quoted string, or the rest of one following a
+// ${...} expression, and returns its value with escape sequences decoded.
+// If the string is interrupted by another ${ the returned type is
+// INTERPOLATION and the lexer continues with the expression's tokens.
+func (l *Lexer) readString() (string, token.Type) {
+	pos := l.pos()
+	var out strings.Builder
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+
+		switch l.ch {
+		case '"':
+			return out.String(), token.STRING
+		case 0:
+			l.errorf(pos, "unterminated string literal")
+			return out.String(), token.STRING
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				l.interpolations = append(l.interpolations, 0)
+				return out.String(), token.INTERPOLATION
+			}
+			out.WriteRune(l.ch)
+		case '\\':
+			if l.peekChar() == 0 {
+				// a trailing backslash is reported as an unterminated string
+				continue
+			}
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape decodes the escape sequence starting at the current '\'
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.pos()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case '\\':
+		out.WriteByte('\\')
+	case '"':
+		out.WriteByte('"')
+	case '$':
+		out.WriteByte('$')
+	case 'u':
+		l.readUnicodeEscape(pos, out)
+	default:
+		l.errorf(pos, "unknown escape sequence: \\%c", l.ch)
+		out.WriteRune(l.ch)
+	}
+}
+
+// readUnicodeEscape decodes a \u{XXXX} escape. The current char is the 'u'.
+func (l *Lexer) readUnicodeEscape(pos token.Position, out *strings.Builder) {
+	if l.peekChar() != '{' {
+		l.errorf(pos, "invalid unicode escape: expected {")
+		return
+	}
+	l.readChar()
+
+	var hex strings.Builder
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+		hex.WriteRune(l.ch)
+	}
+	digits := hex.String()
+
+	if l.peekChar() != '}' {
+		l.errorf(pos, "invalid unicode escape: expected }")
+		return
+	}
+	l.readChar()
+
+	const bitSize = 32
+	code, err := strconv.ParseUint(digits, 16, bitSize)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		l.errorf(pos, "invalid unicode code point: %q", digits)
+		return
+	}
+
+	out.WriteRune(rune(code))
+}
+
+// readRawString reads a backtick-quoted string. Raw strings may span
+// multiple lines and have no escape sequences.
+func (l *Lexer) readRawString() string {
+	pos := l.pos()
+	l.readChar()
+	l.startCapture()
+
+	for l.ch != '`' {
+		if l.ch == 0 {
+			l.errorf(pos, "unterminated raw string literal")
 			break
 		}
+		l.readChar()
 	}
-	return l.input[position:l.position]
+
+	return l.captured()
+}
+
+func (l *Lexer) errorf(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
 }
 
+// skipWhiteSpace skips whitespace and comments
 func (l *Lexer) skipWhiteSpace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readLineComment()
+		case l.ch == '/' && l.peekChar() == '*':
+			l.readBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) readLineComment() {
+	pos := l.pos()
+	l.startCapture()
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	l.addComment(pos, l.captured())
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func (l *Lexer) readBlockComment() {
+	pos := l.pos()
+	l.startCapture()
+	// skip the opening /*
+	l.readChar()
+	l.readChar()
+
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.errorf(pos, "unterminated block comment")
+			l.addComment(pos, l.captured())
+			return
+		}
+		l.readChar()
+	}
+
+	// skip the closing */
+	l.readChar()
+	l.readChar()
+	l.addComment(pos, l.captured())
+}
+
+// addComment records the comment that started at pos and ends at the
+// current char, if comments are being retained
+func (l *Lexer) addComment(pos token.Position, text string) {
+	if !l.keepComments {
+		return
+	}
+
+	l.comments = append(l.comments, token.Comment{
+		Text: text,
+		Pos:  pos,
+		End:  l.pos(),
+	})
 }
 
-func isDigit(ch byte) bool {
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.Type, ch byte) token.Token {
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	default:
+		return false
+	}
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// newOperator returns a token of type double if the current char is
+// followed by next, e.g. += rather than +, and of type single otherwise
+func (l *Lexer) newOperator(single token.Type, next rune, double token.Type) token.Token {
+	if l.peekChar() != next {
+		return newToken(single, l.ch)
+	}
+
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: double, Literal: string(ch) + string(l.ch)}
+}
+
+func newToken(tokenType token.Type, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/dikaeinstein/monkey/token"
@@ -121,6 +123,64 @@ func TestNextToken(t *testing.T) {
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
 		{token.SEMICOLON, ";"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PlusAssign, "+="},
+		{token.INT, "2"},
+		{token.MinusAssign, "-="},
+		{token.INT, "3"},
+		{token.AsteriskAssign, "*="},
+		{token.INT, "4"},
+		{token.SlashAssign, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.AMPERSAND, "&"},
+		{token.PIPE, "|"},
+		{token.CARET, "^"},
+		{token.ShiftLeft, "<<"},
+		{token.ShiftRight, ">>"},
+		{token.LtEQ, "<="},
+		{token.GtEQ, ">="},
+		{token.LT, "<"},
+		{token.GT, ">"},
+		{token.MATCH, "match"},
+		{token.IDENT, "_"},
+		{token.ARROW, "=>"},
+		{token.ASSIGN, "="},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib"},
+		{token.AS, "as"},
+		{token.IDENT, "lib"},
+		{token.EXPORT, "export"},
+		{token.TRY, "try"},
+		{token.CATCH, "catch"},
+		{token.FINALLY, "finally"},
+		{token.THROW, "throw"},
+		{token.TYPE, "type"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.IDENT, "xs"},
+		{token.PIPELINE, "|>"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
 		{token.EOF, ""},
 	}
 
@@ -152,7 +212,7 @@ func buildInput(t *testing.T) string {
 	};
 
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 
 	if (5 < 10) {
@@ -171,5 +231,382 @@ func buildInput(t *testing.T) string {
 
 	{"name": "Jimmy", "age": 72, "band": "Led Zeppelin"};
 	macro(x, y) { x + y; };
+	while for in break continue
+	x = 1; x += 2 -= 3 *= 4 /= 5;
+	a && b || c
+	% & | ^ << >> <= >= < >
+	match _ => =
+	...rest
+	import "lib" as lib export
+	try catch finally throw
+	type p.x
+	xs |> f()
 	`
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + \"ab\""
+
+	testCases := []struct {
+		expectedType token.Type
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.IDENT, token.Position{Offset: 13, Line: 2, Column: 3}, token.Position{Offset: 14, Line: 2, Column: 4}},
+		{token.PLUS, token.Position{Offset: 15, Line: 2, Column: 5}, token.Position{Offset: 16, Line: 2, Column: 6}},
+		{token.STRING, token.Position{Offset: 17, Line: 2, Column: 7}, token.Position{Offset: 21, Line: 2, Column: 11}},
+		{token.EOF, token.Position{Offset: 21, Line: 2, Column: 11}, token.Position{Offset: 21, Line: 2, Column: 11}},
+	}
+
+	l := New(input)
+
+	for i, tt := range testCases {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("testCases[%d] - token type wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("testCases[%d] - pos wrong. Expected=%+v, got=%+v",
+				i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Errorf("testCases[%d] - end wrong. Expected=%+v, got=%+v",
+				i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	l := NewFile("main.monkey", strings.NewReader("let\n x"))
+
+	expected := []token.Position{
+		{Filename: "main.monkey", Offset: 0, Line: 1, Column: 1},
+		{Filename: "main.monkey", Offset: 5, Line: 2, Column: 2},
+	}
+	for i, pos := range expected {
+		tok := l.NextToken()
+		if tok.Pos != pos {
+			t.Errorf("expected[%d] - pos wrong. Expected=%+v, got=%+v", i, pos, tok.Pos)
+		}
+	}
+
+	if s := expected[1].String(); s != "main.monkey:2:2" {
+		t.Errorf("pos.String() wrong. Expected=%q, got=%q", "main.monkey:2:2", s)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block
+   comment */ x / 2;
+/* unterminated`
+
+	testCases := []struct {
+		expectedType     token.Type
+		expectedLiteral  string
+		expectedComments string
+	}{
+		{token.LET, "let", "// leading comment"},
+		{token.IDENT, "x", ""},
+		{token.ASSIGN, "=", ""},
+		{token.INT, "5", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.IDENT, "x", "// trailing comment\n/* block\n   comment */"},
+		{token.SLASH, "/", ""},
+		{token.INT, "2", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.EOF, "", "/* unterminated"},
+	}
+
+	l := NewWithComments(input)
+
+	for i, tt := range testCases {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("testCases[%d] - token type wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("testCases[%d] - literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Comments.Text() != tt.expectedComments {
+			t.Errorf("testCases[%d] - comments wrong. Expected=%q, got=%q",
+				i, tt.expectedComments, tok.Comments.Text())
+		}
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. want=1, got=%d", len(errors))
+	}
+	if errors[0].Error() != "5:1: unterminated block comment" {
+		t.Errorf("wrong error. got=%q", errors[0].Error())
+	}
+}
+
+func TestCommentsDiscardedByDefault(t *testing.T) {
+	l := New("// comment\nx")
+
+	tok := l.NextToken()
+	if tok.Type != token.IDENT {
+		t.Fatalf("token type wrong. Expected=%q, got=%q", token.IDENT, tok.Type)
+	}
+	if tok.Comments != nil {
+		t.Errorf("expected no comments, got=%q", tok.Comments.Text())
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "5 1.5 0.25 1e3 2.5E-3 7e+2 3.x 4e 0xFF 0o17 0b1010 1_000_000 0b12 1_000.5"
+
+	testCases := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "0.25"},
+		{token.FLOAT, "1e3"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "7e+2"},
+		{token.INT, "3"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "4"},
+		{token.IDENT, "e"},
+		{token.INT, "0xFF"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.INT, "0b12"},
+		{token.FLOAT, "1_000.5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range testCases {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("testCases[%d] - token type wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("testCases[%d] - literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let café = \"héllo, 世界\";\nπ"
+
+	testCases := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{token.LET, "let", token.Position{Offset: 0, Line: 1, Column: 1}},
+		{token.IDENT, "café", token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, "=", token.Position{Offset: 10, Line: 1, Column: 10}},
+		{token.STRING, "héllo, 世界", token.Position{Offset: 12, Line: 1, Column: 12}},
+		{token.SEMICOLON, ";", token.Position{Offset: 28, Line: 1, Column: 23}},
+		{token.IDENT, "π", token.Position{Offset: 30, Line: 2, Column: 1}},
+		{token.EOF, "", token.Position{Offset: 32, Line: 2, Column: 2}},
+	}
+
+	l := New(input)
+
+	for i, tt := range testCases {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("testCases[%d] - token type wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("testCases[%d] - literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("testCases[%d] - pos wrong. Expected=%+v, got=%+v",
+				i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	testCases := []struct {
+		input           string
+		expectedLiteral string
+		expectedErrors  []string
+	}{
+		{`"a\nb"`, "a\nb", nil},
+		{`"a\tb"`, "a\tb", nil},
+		{`"say \"hi\""`, `say "hi"`, nil},
+		{`"back\\slash"`, `back\slash`, nil},
+		{`"\u{48}\u{e9}\u{4e16}\u{1F600}"`, "Hé世😀", nil},
+		{"`raw \\n \"string\"\nline two`", "raw \\n \"string\"\nline two", nil},
+		{`"bad \q"`, "bad q", []string{"1:6: unknown escape sequence: \\q"}},
+		{`"\u{110000}"`, "", []string{`1:2: invalid unicode code point: "110000"`}},
+		{`"\u{48"`, "", []string{"1:2: invalid unicode escape: expected }"}},
+		{`"unterminated`, "unterminated", []string{"1:1: unterminated string literal"}},
+		{`"trailing \`, "trailing ", []string{"1:1: unterminated string literal"}},
+		{"`unterminated", "unterminated", []string{"1:1: unterminated raw string literal"}},
+	}
+
+	for _, tt := range testCases {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("%q - token type wrong. Expected=%q, got=%q",
+				tt.input, token.STRING, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%q - literal wrong. Expected=%q, got=%q",
+				tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		errors := l.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Fatalf("%q - wrong number of errors. Expected=%q, got=%q",
+				tt.input, tt.expectedErrors, errors)
+		}
+		for i, err := range errors {
+			if err.Error() != tt.expectedErrors[i] {
+				t.Errorf("%q - wrong error. Expected=%q, got=%q",
+					tt.input, tt.expectedErrors[i], err.Error())
+			}
+		}
+
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q - expected EOF, got=%q", tt.input, tok.Type)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"total: ${price * qty}!" "${ {"a": 1}["a"] } and ${"in ${x}"}" "\${x}"`
+
+	testCases := []struct {
+		expectedType    token.Type
+		expectedLiteral string
+	}{
+		{token.INTERPOLATION, "total: "},
+		{token.IDENT, "price"},
+		{token.ASTERISK, "*"},
+		{token.IDENT, "qty"},
+		{token.STRING, "!"},
+		{token.INTERPOLATION, ""},
+		{token.LBRACE, "{"},
+		{token.STRING, "a"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "a"},
+		{token.RBRACKET, "]"},
+		{token.INTERPOLATION, " and "},
+		{token.INTERPOLATION, "in "},
+		{token.IDENT, "x"},
+		{token.STRING, ""},
+		{token.STRING, ""},
+		{token.STRING, "${x}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range testCases {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("testCases[%d] - token type wrong. Expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("testCases[%d] - literal wrong. Expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+// oneByteReader returns a single byte per Read call so the lexer has to
+// consume its input incrementally
+type oneByteReader struct {
+	s string
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.s) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	p[0] = r.s[0]
+	r.s = r.s[1:]
+	return 1, nil
+}
+
+func TestNewReader(t *testing.T) {
+	input := buildInput(t) + "\n// é comment\n`raw` \"${1.5e3}\" café 0xFF"
+
+	for _, r := range []io.Reader{strings.NewReader(input), &oneByteReader{s: input}} {
+		l := NewReader(r)
+		expected := New(input)
+
+		for i := 0; ; i++ {
+			want := expected.NextToken()
+			got := l.NextToken()
+
+			if got != want {
+				t.Fatalf("token[%d] wrong. Expected=%+v, got=%+v", i, want, got)
+			}
+			if want.Type == token.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestIterator(t *testing.T) {
+	l := New("let x = 5;")
+
+	expected := []token.Type{token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON}
+	var got []token.Type
+
+	it := l.Iter()
+	for it.Next() {
+		got = append(got, it.Token().Type)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("wrong number of tokens. Expected=%v, got=%v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("token[%d] wrong. Expected=%q, got=%q", i, expected[i], got[i])
+		}
+	}
+
+	if it.Token().Type != token.EOF {
+		t.Errorf("expected iterator to stop at EOF, got=%q", it.Token().Type)
+	}
+	if it.Next() {
+		t.Errorf("expected Next to keep returning false after EOF")
+	}
+}
@@ -2,6 +2,7 @@ package object
 
 import (
 	"fmt"
+	"strconv"
 )
 
 type NamedBuiltinFunction struct {
@@ -21,7 +22,7 @@ var builtins = []NamedBuiltinFunction{
 
 			switch arg := args[0].(type) {
 			case String:
-				return Integer(len(arg))
+				return Integer(arg.Len())
 			case *Array:
 				return Integer(len(arg.Elements))
 			default:
@@ -128,6 +129,58 @@ var builtins = []NamedBuiltinFunction{
 			return nil
 		},
 	},
+	{
+		Name: "int",
+		Builtin: func(args ...Object) Object {
+			const allowedNumOfArgs = 1
+			err := checkArgsLen(allowedNumOfArgs, args)
+			if IsError(err) {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case Integer:
+				return arg
+			case Float:
+				return Integer(arg)
+			case String:
+				const bitSize = 64
+				i, err := strconv.ParseInt(string(arg), 0, bitSize)
+				if err != nil {
+					return newError("could not convert %q to INTEGER", arg)
+				}
+				return Integer(i)
+			default:
+				return newError("argument to `int` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	{
+		Name: "float",
+		Builtin: func(args ...Object) Object {
+			const allowedNumOfArgs = 1
+			err := checkArgsLen(allowedNumOfArgs, args)
+			if IsError(err) {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case Integer:
+				return Float(arg)
+			case Float:
+				return arg
+			case String:
+				const bitSize = 64
+				f, err := strconv.ParseFloat(string(arg), bitSize)
+				if err != nil {
+					return newError("could not convert %q to FLOAT", arg)
+				}
+				return Float(f)
+			default:
+				return newError("argument to `float` not supported, got %s", arg.Type())
+			}
+		},
+	},
 }
 
 func Builtins() []NamedBuiltinFunction {
@@ -11,9 +11,39 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s, outer: nil}
 }
 
+// NewModuleEnvironment returns the environment of a module imported from
+// importer. It shares the modules loaded by importer.
+func NewModuleEnvironment(importer *Environment) *Environment {
+	env := NewEnvironment()
+	env.modules = importer.Modules()
+	return env
+}
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	modules *Modules
+}
+
+// Modules records the modules loaded by a program, so that each is evaluated
+// only once. Loading holds the paths of the modules being evaluated, in
+// import order, to detect import cycles.
+type Modules struct {
+	Loaded  map[string]*Module
+	Loading []string
+}
+
+// Modules returns the modules loaded by the program e belongs to
+func (e *Environment) Modules() *Modules {
+	for e.outer != nil {
+		e = e.outer
+	}
+
+	if e.modules == nil {
+		e.modules = &Modules{Loaded: make(map[string]*Module)}
+	}
+	return e.modules
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +58,18 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Assign updates the innermost existing binding of name. It reports false
+// if name is not bound in e or any enclosing environment.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+
+	return false
+}
@@ -3,7 +3,10 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/dikaeinstein/monkey/ast"
 	"github.com/dikaeinstein/monkey/code"
@@ -30,16 +33,23 @@ type Object interface {
 const (
 	ARRAY            Type = "ARRAY"
 	BOOLEAN          Type = "BOOLEAN"
+	BOUNDMETHOD      Type = "BOUNDMETHOD"
 	BUILTIN          Type = "BUILTIN"
+	CELL             Type = "CELL"
 	COMPILEDFUNCTION Type = "COMPILEDFUNCTION"
 	CLOSURE          Type = "CLOSURE"
 	ERROR            Type = "ERROR"
+	FLOAT            Type = "FLOAT"
 	FUNCTION         Type = "FUNCTION"
 	HASH             Type = "HASH"
 	INTEGER          Type = "INTEGER"
+	ITERATOR         Type = "ITERATOR"
 	MACRO            Type = "MACRO"
+	MODULE           Type = "MODULE"
 	NULL             Type = "NULL"
 	QUOTE            Type = "QUOTE"
+	RECORD           Type = "RECORD"
+	RECORDTYPE       Type = "RECORDTYPE"
 	STRING           Type = "STRING"
 )
 
@@ -48,11 +58,89 @@ type Integer int64
 func (i Integer) Type() Type      { return INTEGER }
 func (i Integer) Inspect() string { return fmt.Sprint(i) }
 
+type Float float64
+
+func (f Float) Type() Type { return FLOAT }
+
+// Inspect formats the float so that it always reads as a float, i.e. 2.0
+// rather than 2.
+func (f Float) Inspect() string {
+	s := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
+// AsFloat returns the value of a numeric object as a float64. ok is false
+// if obj is neither an Integer nor a Float.
+func AsFloat(obj Object) (f float64, ok bool) {
+	switch obj := obj.(type) {
+	case Integer:
+		return float64(obj), true
+	case Float:
+		return float64(obj), true
+	default:
+		return 0, false
+	}
+}
+
 type String string
 
 func (s String) Type() Type      { return STRING }
 func (s String) Inspect() string { return string(s) }
 
+// Len returns the number of characters (runes) in the string
+func (s String) Len() int { return utf8.RuneCountInString(string(s)) }
+
+// At returns the character at index i, counted in runes. ok is false if i
+// is out of range.
+func (s String) At(i int64) (ch String, ok bool) {
+	if i < 0 {
+		return "", false
+	}
+
+	for _, r := range string(s) {
+		if i == 0 {
+			return String(r), true
+		}
+		i--
+	}
+
+	return "", false
+}
+
+// Slice returns the characters (runes) from index lo up to but not
+// including hi, which must be within range
+func (s String) Slice(lo, hi int64) String {
+	return String([]rune(string(s))[lo:hi])
+}
+
+// SliceBounds resolves the low and high bounds of a slice of a sequence with
+// length elements. Negative bounds count from the end, and bounds out of
+// range are clamped so that 0 <= lo <= hi <= length.
+func SliceBounds(length, low, high int64) (lo, hi int64) {
+	clamp := func(i int64) int64 {
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > length {
+			return length
+		}
+		return i
+	}
+
+	lo, hi = clamp(low), clamp(high)
+	if hi < lo {
+		hi = lo
+	}
+
+	return lo, hi
+}
+
 type Boolean bool
 
 func (b Boolean) Type() Type      { return BOOLEAN }
@@ -70,6 +158,8 @@ func (e Error) Inspect() string { return fmt.Sprintf("Error: %s", e) }
 
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -79,8 +169,16 @@ func (fn *Function) Inspect() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fn.Parameters {
-		params = append(params, p.String())
+	required := len(fn.Parameters) - len(fn.Defaults)
+	for i, p := range fn.Parameters {
+		if i < required {
+			params = append(params, p.String())
+		} else {
+			params = append(params, p.String()+" = "+fn.Defaults[i-required].String())
+		}
+	}
+	if fn.Rest != nil {
+		params = append(params, "..."+fn.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -93,6 +191,19 @@ func (fn *Function) Inspect() string {
 	return out.String()
 }
 
+// FormatArity describes the number of arguments a function accepts, e.g.
+// "2", "1..3" or "1.." for a variadic function
+func FormatArity(required, optional int, variadic bool) string {
+	switch {
+	case variadic:
+		return fmt.Sprintf("%d..", required)
+	case optional > 0:
+		return fmt.Sprintf("%d..%d", required, required+optional)
+	default:
+		return fmt.Sprintf("%d", required)
+	}
+}
+
 type BuiltInFunction func(args ...Object) Object
 
 func (bf BuiltInFunction) Type() Type      { return BUILTIN }
@@ -138,6 +249,184 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// Iterator steps through the elements of an array, the characters of a
+// string or the keys of a hash, in sorted order
+type Iterator struct {
+	elements []Object
+	next     int
+}
+
+// NewIterator returns an iterator over obj. ok is false if obj cannot be
+// iterated over.
+func NewIterator(obj Object) (it *Iterator, ok bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]Object, len(obj.Elements))
+		copy(elements, obj.Elements)
+		return &Iterator{elements: elements}, true
+	case String:
+		elements := []Object{}
+		for _, r := range string(obj) {
+			elements = append(elements, String(r))
+		}
+		return &Iterator{elements: elements}, true
+	case *Hash:
+		keys := make([]string, 0, len(obj.Pairs))
+		for k := range obj.Pairs {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+
+		elements := make([]Object, len(keys))
+		for i, k := range keys {
+			elements[i] = String(k)
+		}
+		return &Iterator{elements: elements}, true
+	default:
+		return nil, false
+	}
+}
+
+func (it *Iterator) Type() Type      { return ITERATOR }
+func (it *Iterator) Inspect() string { return "iterator" }
+
+// Next returns the next element. ok is false once the iterator is exhausted.
+func (it *Iterator) Next() (obj Object, ok bool) {
+	if it.next >= len(it.elements) {
+		return nil, false
+	}
+
+	obj = it.elements[it.next]
+	it.next++
+	return obj, true
+}
+
+// Module is an imported module. Exports holds the values of the names it
+// exports.
+type Module struct {
+	Name    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() Type      { return MODULE }
+func (m *Module) Inspect() string { return fmt.Sprintf("module(%s)", m.Name) }
+
+// RecordType is a type declared by a type statement. Calling it with a
+// value for each field creates a Record.
+type RecordType struct {
+	Name   string
+	Fields []string
+	// Methods holds the functions called on the records of the type,
+	// taking the record as their first argument
+	Methods map[string]Object
+}
+
+func (rt *RecordType) Type() Type      { return RECORDTYPE }
+func (rt *RecordType) Inspect() string { return fmt.Sprintf("type(%s)", rt.Name) }
+
+// Record is a value of a record type
+type Record struct {
+	RecordType *RecordType
+	Fields     map[string]Object
+}
+
+// NewRecord returns a record of type rt with the given field values, in
+// the order the fields were declared
+func NewRecord(rt *RecordType, values []Object) *Record {
+	fields := make(map[string]Object, len(rt.Fields))
+	for i, name := range rt.Fields {
+		fields[name] = values[i]
+	}
+
+	return &Record{RecordType: rt, Fields: fields}
+}
+
+func (r *Record) Type() Type { return RECORD }
+func (r *Record) Inspect() string {
+	fields := make([]string, len(r.RecordType.Fields))
+	for i, name := range r.RecordType.Fields {
+		fields[i] = fmt.Sprintf("%s: %s", name, r.Fields[name].Inspect())
+	}
+
+	return r.RecordType.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Get returns the value of the field name, or the method name bound to r.
+// ok is false if the type has neither.
+func (r *Record) Get(name string) (obj Object, ok bool) {
+	if val, ok := r.Fields[name]; ok {
+		return val, true
+	}
+	if method, ok := r.RecordType.Methods[name]; ok {
+		return &BoundMethod{Receiver: r, Name: name, Method: method}, true
+	}
+
+	return nil, false
+}
+
+// Set assigns val to the field name. ok is false if the type has no such
+// field.
+func (r *Record) Set(name string, val Object) bool {
+	if _, ok := r.Fields[name]; !ok {
+		return false
+	}
+
+	r.Fields[name] = val
+	return true
+}
+
+// Equal reports whether r and other are records of the same type with equal
+// fields. Numbers, strings and booleans are compared by value, records field
+// by field and any other values by identity.
+func (r *Record) Equal(other *Record) bool {
+	if r.RecordType != other.RecordType {
+		return false
+	}
+
+	for _, name := range r.RecordType.Fields {
+		if !fieldsEqual(r.Fields[name], other.Fields[name]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func fieldsEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Record:
+		b, ok := b.(*Record)
+		return ok && a.Equal(b)
+	case Integer:
+		if b, ok := b.(Integer); ok {
+			return a == b
+		}
+	case BuiltInFunction:
+		// functions can't be compared in Go
+		return false
+	}
+
+	if x, ok := AsFloat(a); ok {
+		y, ok := AsFloat(b)
+		return ok && x == y
+	}
+
+	return a == b
+}
+
+// BoundMethod is a method looked up on a record. Calling it calls Method
+// with Receiver prepended to the arguments.
+type BoundMethod struct {
+	Receiver *Record
+	Name     string
+	Method   Object
+}
+
+func (bm *BoundMethod) Type() Type { return BOUNDMETHOD }
+func (bm *BoundMethod) Inspect() string {
+	return fmt.Sprintf("method(%s.%s)", bm.Receiver.RecordType.Name, bm.Name)
+}
+
 type Exp struct{ ast.Expression }
 
 type Quote struct{ ast.Node }
@@ -176,6 +465,14 @@ type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	// NumDefaults is the number of trailing parameters with a default
+	// value. The function starts at DefaultEntries[i] when i of them have
+	// been passed, which evaluates the default values of the others.
+	NumDefaults    int
+	DefaultEntries []int
+	// Variadic is set if the function collects the extra arguments into
+	// an array, stored in the local following the parameters
+	Variadic bool
 }
 
 func (cf *CompiledFunction) Type() Type { return COMPILEDFUNCTION }
@@ -193,6 +490,16 @@ func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
 
+// Cell holds a local variable that has been captured by a closure, so that
+// assignments made by the closure and by the enclosing function are seen
+// by both.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() Type      { return CELL }
+func (c *Cell) Inspect() string { return c.Value.Inspect() }
+
 func IsHashable(key Object) (String, bool) {
 	var k String
 
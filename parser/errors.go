@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dikaeinstein/monkey/token"
+)
+
+// ErrorCode identifies the kind of a ParseError so tools can handle
+// diagnostics without matching on the message text
+type ErrorCode string
+
+const (
+	// ErrUnexpectedToken is reported when the next token is not the expected one
+	ErrUnexpectedToken ErrorCode = "unexpected-token"
+	// ErrNoPrefixParseFn is reported when a token cannot start an expression
+	ErrNoPrefixParseFn ErrorCode = "no-prefix-parse-fn"
+	// ErrInvalidInteger is reported when an integer literal cannot be parsed
+	ErrInvalidInteger ErrorCode = "invalid-integer"
+	// ErrIntegerOutOfRange is reported when an integer literal does not fit
+	// in 64 bits
+	ErrIntegerOutOfRange ErrorCode = "integer-out-of-range"
+	// ErrInvalidFloat is reported when a float literal cannot be parsed
+	ErrInvalidFloat ErrorCode = "invalid-float"
+	// ErrLexical is reported for errors found by the lexer, such as an
+	// unterminated comment
+	ErrLexical ErrorCode = "lexical-error"
+	// ErrOutsideLoop is reported for a break or continue that is not inside
+	// the body of a loop
+	ErrOutsideLoop ErrorCode = "outside-loop"
+	// ErrInvalidAssignment is reported when the left-hand side of an
+	// assignment is not something that can be assigned to
+	ErrInvalidAssignment ErrorCode = "invalid-assignment"
+	// ErrInvalidPattern is reported when a match arm or a hash pattern key
+	// does not start with a valid pattern
+	ErrInvalidPattern ErrorCode = "invalid-pattern"
+	// ErrInvalidParameter is reported for a parameter without a default
+	// value following one with a default value, or a method without a
+	// parameter for the receiver
+	ErrInvalidParameter ErrorCode = "invalid-parameter"
+	// ErrOutsideTopLevel is reported for an import or export statement that
+	// is not at the top level of the program
+	ErrOutsideTopLevel ErrorCode = "outside-top-level"
+	// ErrDuplicateMember is reported when a type declares two fields or
+	// methods with the same name
+	ErrDuplicateMember ErrorCode = "duplicate-member"
+	// ErrInvalidPipeline is reported when the right-hand side of a |> is
+	// not a call
+	ErrInvalidPipeline ErrorCode = "invalid-pipeline"
+)
+
+// ParseError is a single diagnostic produced while parsing
+type ParseError struct {
+	Pos      token.Position
+	Expected []token.Type // token types that would have been accepted, if known
+	Actual   token.Token  // the offending token
+	Msg      string
+	Code     ErrorCode
+}
+
+// Error implements the error interface. The message is prefixed with the
+// position if one is known.
+func (e *ParseError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+
+	return e.Msg
+}
+
+// ErrorList is a list of *ParseErrors. The zero value is an empty list
+// ready to use.
+type ErrorList []*ParseError
+
+// Add appends a ParseError to the list
+func (l *ErrorList) Add(e *ParseError) {
+	*l = append(*l, e)
+}
+
+// Len, Swap and Less implement sort.Interface
+func (l ErrorList) Len() int      { return len(l) }
+func (l ErrorList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func (l ErrorList) Less(i, j int) bool {
+	e, f := l[i].Pos, l[j].Pos
+	if e.Line != f.Line {
+		return e.Line < f.Line
+	}
+	if e.Column != f.Column {
+		return e.Column < f.Column
+	}
+
+	return l[i].Msg < l[j].Msg
+}
+
+// Sort sorts the list by position, then by message
+func (l ErrorList) Sort() {
+	sort.Stable(l)
+}
+
+// RemoveMultiples sorts the list and removes all but the first error per line
+func (l *ErrorList) RemoveMultiples() {
+	sort.Stable(l)
+
+	var last token.Position // initial last.Line is != any legal error line
+	i := 0
+	for _, e := range *l {
+		if e.Pos.Line != last.Line || !e.Pos.IsValid() {
+			last = e.Pos
+			(*l)[i] = e
+			i++
+		}
+	}
+
+	*l = (*l)[0:i]
+}
+
+// Error implements the error interface
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns an error equivalent to this error list.
+// If the list is empty, Err returns nil.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Strings renders each error as a "line:col: message" string
+func (l ErrorList) Strings() []string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+
+	return msgs
+}
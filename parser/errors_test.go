@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/dikaeinstein/monkey/lexer"
+	"github.com/dikaeinstein/monkey/token"
+)
+
+func TestParseErrorDetails(t *testing.T) {
+	l := lexer.New("let x 5;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors, got none")
+	}
+
+	err := errors[0]
+	if err.Code != ErrUnexpectedToken {
+		t.Errorf("wrong code. want=%q, got=%q", ErrUnexpectedToken, err.Code)
+	}
+	if len(err.Expected) != 1 || err.Expected[0] != token.ASSIGN {
+		t.Errorf("wrong expected tokens. want=[%s], got=%v", token.ASSIGN, err.Expected)
+	}
+	if err.Actual.Type != token.INT || err.Actual.Literal != "5" {
+		t.Errorf("wrong actual token. got=%+v", err.Actual)
+	}
+	want := token.Position{Offset: 6, Line: 1, Column: 7}
+	if err.Pos != want {
+		t.Errorf("wrong position. want=%+v, got=%+v", want, err.Pos)
+	}
+	if err.Msg != "expected next token to be =, got INT instead" {
+		t.Errorf("wrong message. got=%q", err.Msg)
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	pos := func(line, col int) token.Position {
+		return token.Position{Line: line, Column: col}
+	}
+
+	var list ErrorList
+	list.Add(&ParseError{Pos: pos(2, 1), Msg: "c"})
+	list.Add(&ParseError{Pos: pos(1, 5), Msg: "b"})
+	list.Add(&ParseError{Pos: pos(1, 2), Msg: "a"})
+	list.Add(&ParseError{Pos: pos(2, 1), Msg: "c"})
+
+	list.Sort()
+	expected := []string{"1:2: a", "1:5: b", "2:1: c", "2:1: c"}
+	for i, msg := range list.Strings() {
+		if msg != expected[i] {
+			t.Errorf("list[%d] wrong. want=%q, got=%q", i, expected[i], msg)
+		}
+	}
+
+	list.RemoveMultiples()
+	expected = []string{"1:2: a", "2:1: c"}
+	if len(list) != len(expected) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d", len(expected), len(list))
+	}
+	for i, msg := range list.Strings() {
+		if msg != expected[i] {
+			t.Errorf("list[%d] wrong. want=%q, got=%q", i, expected[i], msg)
+		}
+	}
+
+	if list.Error() != "1:2: a (and 1 more errors)" {
+		t.Errorf("wrong Error(). got=%q", list.Error())
+	}
+	if (ErrorList{}).Err() != nil {
+		t.Errorf("empty list should have nil Err()")
+	}
+}
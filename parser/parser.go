@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
 
 	"github.com/dikaeinstein/monkey/ast"
@@ -12,27 +14,64 @@ import (
 const (
 	_ uint = iota
 	LOWEST
+	ASSIGN      // = or +=
+	LOGICALOR   // ||
+	LOGICALAND  // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	PIPELINE    // x |> f()
+	SUM         // + - | ^
+	PRODUCT     // * / % & << >>
 	PREFIX      // !X or -X
 	CALL        // myFunction(X)
-	INDEX       // array[index]
+	INDEX       // array[index] or record.field
 )
 
 // operator precedence
 var precedences = map[token.Type]uint{
-	token.EQ:       EQUALS,
-	token.NotEQ:    EQUALS,
-	token.GT:       LESSGREATER,
-	token.LT:       LESSGREATER,
-	token.MINUS:    SUM,
-	token.PLUS:     SUM,
-	token.ASTERISK: PRODUCT,
-	token.SLASH:    PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:         ASSIGN,
+	token.PlusAssign:     ASSIGN,
+	token.MinusAssign:    ASSIGN,
+	token.AsteriskAssign: ASSIGN,
+	token.SlashAssign:    ASSIGN,
+	token.OR:             LOGICALOR,
+	token.AND:            LOGICALAND,
+	token.EQ:             EQUALS,
+	token.NotEQ:          EQUALS,
+	token.GT:             LESSGREATER,
+	token.LT:             LESSGREATER,
+	token.GtEQ:           LESSGREATER,
+	token.LtEQ:           LESSGREATER,
+	token.PIPELINE:       PIPELINE,
+	token.MINUS:          SUM,
+	token.PLUS:           SUM,
+	token.PIPE:           SUM,
+	token.CARET:          SUM,
+	token.ASTERISK:       PRODUCT,
+	token.SLASH:          PRODUCT,
+	token.PERCENT:        PRODUCT,
+	token.AMPERSAND:      PRODUCT,
+	token.ShiftLeft:      PRODUCT,
+	token.ShiftRight:     PRODUCT,
+	token.LPAREN:         CALL,
+	token.LBRACKET:       INDEX,
+	token.DOT:            INDEX,
+}
+
+// statementKeywords are the tokens that start a statement. They are used as
+// synchronization points when recovering from a syntax error.
+var statementKeywords = map[token.Type]bool{
+	token.LET:      true,
+	token.RETURN:   true,
+	token.WHILE:    true,
+	token.FOR:      true,
+	token.BREAK:    true,
+	token.CONTINUE: true,
+	token.IMPORT:   true,
+	token.EXPORT:   true,
+	token.TRY:      true,
+	token.THROW:    true,
+	token.TYPE:     true,
 }
 
 type (
@@ -44,11 +83,21 @@ type (
 // tokens to generate the AST
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors ErrorList
 
 	curToken  token.Token
 	peekToken token.Token
 
+	// depth is the number of braces left open up to and including curToken
+	depth int
+	// panicking is set once a syntax error has been reported in the
+	// current statement; further errors are suppressed until the parser
+	// has synchronized
+	panicking bool
+	// loops is the number of loop bodies enclosing curToken within the
+	// current function
+	loops int
+
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
 }
@@ -67,19 +116,24 @@ func New(l *lexer.Lexer) *Parser {
 	// register parseFn for prefix operators
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.INTERPOLATION, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	// register parseFn for infix operators
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NotEQ, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
@@ -88,8 +142,23 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
+	p.registerInfix(token.GtEQ, p.parseInfixExpression)
+	p.registerInfix(token.LtEQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.ShiftLeft, p.parseInfixExpression)
+	p.registerInfix(token.ShiftRight, p.parseInfixExpression)
+	p.registerInfix(token.PIPELINE, p.parsePipelineExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PlusAssign, p.parseAssignExpression)
+	p.registerInfix(token.MinusAssign, p.parseAssignExpression)
+	p.registerInfix(token.AsteriskAssign, p.parseAssignExpression)
+	p.registerInfix(token.SlashAssign, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseSelectorExpression)
 
 	return p
 }
@@ -102,13 +171,21 @@ func (p *Parser) registerInfix(t token.Type, fn infixParseFn) {
 	p.infixParseFns[t] = fn
 }
 
-func (p *Parser) Errors() []string {
+// Errors returns the diagnostics collected while parsing, sorted by position
+func (p *Parser) Errors() ErrorList {
 	return p.errors
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.depth++
+	case token.RBRACE:
+		p.depth--
+	}
 }
 
 // ParseProgram recursively parses the tokens and returns the root node
@@ -117,28 +194,130 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		// if stmt != nil {
-		program.Statements = append(program.Statements, stmt)
-		// }
+		if stmt := p.parseStatement(); stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
+	for _, err := range p.l.Errors() {
+		p.errors.Add(&ParseError{Pos: err.Pos, Msg: err.Msg, Code: ErrLexical})
+	}
+	p.errors.Sort()
+
 	return program
 }
 
+// ParseFile parses the source file named filename. The error returned is
+// either an I/O error or an ErrorList of the syntax errors found.
+func ParseFile(filename string) (*ast.Program, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := New(lexer.NewFile(filename, f))
+	program := p.ParseProgram()
+
+	return program, p.Errors().Err()
+}
+
+// parseStatement parses a single statement. If a syntax error occurs the
+// parser skips ahead to the next synchronization point and nil is returned.
 func (p *Parser) parseStatement() ast.Statement {
+	depth := p.depth
+	outer := p.panicking
+	p.panicking = false
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if s := p.parseLetStatement(); s != nil {
+			stmt = s
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if s := p.parseReturnStatement(); s != nil {
+			stmt = s
+		}
+	case token.WHILE:
+		if s := p.parseWhileStatement(); s != nil {
+			stmt = s
+		}
+	case token.FOR:
+		stmt = p.parseForStatement()
+	case token.BREAK:
+		if s := p.parseBreakStatement(); s != nil {
+			stmt = s
+		}
+	case token.CONTINUE:
+		if s := p.parseContinueStatement(); s != nil {
+			stmt = s
+		}
+	case token.TRY:
+		if s := p.parseTryStatement(); s != nil {
+			stmt = s
+		}
+	case token.THROW:
+		if s := p.parseThrowStatement(); s != nil {
+			stmt = s
+		}
+	case token.TYPE:
+		if s := p.parseTypeStatement(); s != nil {
+			stmt = s
+		}
+	case token.IMPORT:
+		if s := p.parseImportStatement(); s != nil {
+			stmt = s
+		}
+	case token.EXPORT:
+		if s := p.parseExportStatement(); s != nil {
+			stmt = s
+		}
 	default:
-		return p.parseExpressionStatement()
+		if s := p.parseExpressionStatement(); s != nil {
+			stmt = s
+		}
+	}
+
+	if p.panicking {
+		p.synchronize(depth)
+		stmt = nil
+	}
+	p.panicking = outer
+
+	return stmt
+}
+
+// synchronize discards tokens until curToken ends the statement that
+// started at the given brace depth. It stops on a ';', before a '}' or a
+// statement keyword, or once the enclosing block has been closed.
+func (p *Parser) synchronize(depth int) {
+	for !p.curTokenIs(token.EOF) {
+		if p.depth < depth {
+			return
+		}
+
+		if p.depth == depth {
+			if p.curTokenIs(token.SEMICOLON) ||
+				p.peekTokenIs(token.RBRACE) ||
+				statementKeywords[p.peekToken.Type] {
+				return
+			}
+		}
+
+		p.nextToken()
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func (p *Parser) parseLetStatement() ast.Statement {
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		if s := p.parseDestructuringLetStatement(); s != nil {
+			return s
+		}
+		return nil
+	}
+
 	stmt := &ast.LetStatement{Token: p.curToken}
 	if !p.expectPeek(token.IDENT) {
 		return nil
@@ -164,13 +343,457 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{Token: p.curToken}
+// parseDestructuringLetStatement parses `let [a, b, ...rest] = value;` or
+// `let {a, b} = value;`
+func (p *Parser) parseDestructuringLetStatement() *ast.DestructuringLetStatement {
+	stmt := &ast.DestructuringLetStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Open = p.curToken
+
+	end := token.RBRACKET
+	if stmt.IsHash() {
+		end = token.RBRACE
+	}
+
+	for !p.peekTokenIs(end) {
+		if !stmt.IsHash() && p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names,
+			&ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(end) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	stmt := &ast.ReturnStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.ReturnValue = p.parseExpression(LOWEST)
+
+	// if there's a ';' in the return statement then consume it
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+
+	// allow statement to end with either semicolon or not
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForStatement parses both the C-style `for (init; cond; post)` loop
+// and the `for (x in iterable)` loop
+func (p *Parser) parseForStatement() ast.Statement {
+	tok := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+		if s := p.parseForInStatement(tok); s != nil {
+			return s
+		}
+		return nil
+	}
+
+	stmt := &ast.ForStatement{Token: tok}
+
+	if !p.curTokenIs(token.SEMICOLON) {
+		if stmt.Init = p.parseSimpleStatement(); stmt.Init == nil {
+			return nil
+		}
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Condition = p.parseExpression(LOWEST)
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	if !p.curTokenIs(token.RPAREN) {
+		if stmt.Post = p.parseSimpleStatement(); stmt.Post == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForInStatement(tok token.Token) *ast.ForInStatement {
+	stmt := &ast.ForInStatement{Token: tok}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken()
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseSimpleStatement parses the let or expression statement allowed in
+// the header of a for loop
+func (p *Parser) parseSimpleStatement() ast.Statement {
+	if p.curTokenIs(token.LET) {
+		if s := p.parseLetStatement(); s != nil {
+			return s
+		}
+		return nil
+	}
+
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	if stmt.Expression = p.parseExpression(LOWEST); stmt.Expression == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+// parseLoopBody parses the block of a loop, in which break and continue
+// are allowed
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	body := p.parseBlockStatement()
+	p.loops--
+
+	return body
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if !p.checkInLoop() {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if !p.checkInLoop() {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// checkInLoop reports an error if curToken is not inside the body of a loop
+func (p *Parser) checkInLoop() bool {
+	if p.loops > 0 {
+		return true
+	}
+
+	p.addError(&ParseError{
+		Pos:    p.curToken.Pos,
+		Actual: p.curToken,
+		Msg:    fmt.Sprintf("%s outside loop", p.curToken.Literal),
+		Code:   ErrOutsideLoop,
+	})
+	return false
+}
+
+// parseTryStatement parses `try { } catch (e) { } finally { }`, where either
+// the catch or the finally block may be left out
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+
+	if !p.peekTokenIs(token.CATCH) && !p.peekTokenIs(token.FINALLY) {
+		p.addError(&ParseError{
+			Pos:      p.peekToken.Pos,
+			Expected: []token.Type{token.CATCH, token.FINALLY},
+			Actual:   p.peekToken,
+			Msg: fmt.Sprintf("expected next token to be %s or %s, got %s instead",
+				token.CATCH, token.FINALLY, p.peekToken.Type),
+			Code: ErrUnexpectedToken,
+		})
+		return nil
+	}
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseTypeStatement parses `type Name { field, ..., fn method(self, ...) { ... } }`.
+// The fields must be separated by commas, the comma after a method is
+// optional.
+func (p *Parser) parseTypeStatement() *ast.TypeStatement {
+	stmt := &ast.TypeStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	members := make(map[string]bool)
+	for !p.peekTokenIs(token.RBRACE) {
+		var name *ast.Identifier
+		isMethod := p.peekTokenIs(token.FUNCTION)
+
+		if isMethod {
+			p.nextToken()
+			method := p.parseMethod()
+			if method == nil {
+				return nil
+			}
+			name = method.Name
+			stmt.Methods = append(stmt.Methods, method)
+		} else {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			stmt.Fields = append(stmt.Fields, name)
+		}
+
+		if members[name.Value] {
+			p.addError(&ParseError{
+				Pos:    name.Pos(),
+				Actual: name.Token,
+				Msg:    fmt.Sprintf("duplicate member %s in type %s", name.Value, stmt.Name.Value),
+				Code:   ErrDuplicateMember,
+			})
+			return nil
+		}
+		members[name.Value] = true
+
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+		if isMethod {
+			if p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+			}
+		} else if !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	stmt.Rbrace = p.curToken.Pos
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseMethod parses `fn name(self, ...) { ... }` with curToken on the 'fn'
+func (p *Parser) parseMethod() *ast.Method {
+	fnLit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	method := &ast.Method{
+		Name:     &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		Function: fnLit,
+	}
+
+	if !p.expectPeek(token.LPAREN) || !p.parseParameters(fnLit) {
+		return nil
+	}
+
+	if len(fnLit.Parameters) == len(fnLit.Defaults) {
+		p.addError(&ParseError{
+			Pos:    method.Name.Pos(),
+			Actual: method.Name.Token,
+			Msg:    fmt.Sprintf("method %s has no parameter for the receiver", method.Name.Value),
+			Code:   ErrInvalidParameter,
+		})
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.parseFunctionBody(fnLit)
+
+	return method
+}
+
+// parseThrowStatement parses `throw value;`
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseImportStatement parses `import "path" as name;`
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if !p.checkTopLevel() {
+		return nil
+	}
 
-	p.nextToken()
-	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.AS) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	// if there's a ';' in the return statement then consume it
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -178,21 +801,56 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	stmt := &ast.ExpressionStatement{Token: p.curToken}
-	stmt.Expression = p.parseExpression(LOWEST)
+// parseExportStatement parses `export let ...;`
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if !p.checkTopLevel() {
+		return nil
+	}
 
-	// allow statement to end with either semicolon or not
-	if p.peekTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.TYPE) {
 		p.nextToken()
+		ts := p.parseTypeStatement()
+		if ts == nil {
+			return nil
+		}
+		stmt.Statement = ts
+		return stmt
+	}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	if stmt.Statement = p.parseLetStatement(); stmt.Statement == nil {
+		return nil
 	}
 
 	return stmt
 }
 
+// checkTopLevel reports an error if curToken is not at the top level of
+// the program
+func (p *Parser) checkTopLevel() bool {
+	if p.depth == 0 {
+		return true
+	}
+
+	p.addError(&ParseError{
+		Pos:    p.curToken.Pos,
+		Actual: p.curToken,
+		Msg:    fmt.Sprintf("%s outside top level", p.curToken.Literal),
+		Code:   ErrOutsideTopLevel,
+	})
+	return false
+}
+
 func (p *Parser) noPrefixParseFnError(t token.Type) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(&ParseError{
+		Pos:    p.curToken.Pos,
+		Actual: p.curToken,
+		Msg:    fmt.Sprintf("no prefix parse function for %s found", t),
+		Code:   ErrNoPrefixParseFn,
+	})
 }
 
 func (p *Parser) parseExpression(precedence uint) ast.Expression {
@@ -229,9 +887,22 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	const bitSize = 64
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, bitSize)
+	if errors.Is(err, strconv.ErrRange) {
+		p.addError(&ParseError{
+			Pos:    p.curToken.Pos,
+			Actual: p.curToken,
+			Msg:    fmt.Sprintf("integer literal %s is out of range", p.curToken.Literal),
+			Code:   ErrIntegerOutOfRange,
+		})
+		return nil
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&ParseError{
+			Pos:    p.curToken.Pos,
+			Actual: p.curToken,
+			Msg:    fmt.Sprintf("could not parse %q as integer", p.curToken.Literal),
+			Code:   ErrInvalidInteger,
+		})
 		return nil
 	}
 
@@ -239,10 +910,58 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return intLit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	floatLit := &ast.FloatLiteral{Token: p.curToken}
+
+	const bitSize = 64
+	value, err := strconv.ParseFloat(p.curToken.Literal, bitSize)
+	if err != nil {
+		p.addError(&ParseError{
+			Pos:    p.curToken.Pos,
+			Actual: p.curToken,
+			Msg:    fmt.Sprintf("could not parse %q as float", p.curToken.Literal),
+			Code:   ErrInvalidFloat,
+		})
+		return nil
+	}
+
+	floatLit.Value = value
+	return floatLit
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for p.curTokenIs(token.INTERPOLATION) {
+		str.Parts = append(str.Parts, p.parseStringLiteral())
+
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.INTERPOLATION) && !p.peekTokenIs(token.STRING) {
+			p.addError(&ParseError{
+				Pos:      p.peekToken.Pos,
+				Expected: []token.Type{token.INTERPOLATION, token.STRING},
+				Actual:   p.peekToken,
+				Msg: fmt.Sprintf("expected } to close interpolated expression, got %s instead",
+					p.peekToken.Type),
+				Code: ErrUnexpectedToken,
+			})
+			return nil
+		}
+		p.nextToken()
+	}
+
+	// curToken is the STRING holding the rest of the string
+	str.Parts = append(str.Parts, p.parseStringLiteral())
+
+	return str
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
 		Token: p.curToken,
@@ -277,6 +996,68 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return infixExp
 }
 
+// parsePipelineExpression parses `left |> f(args)` and rewrites it to the
+// call `f(left, args)`. The operator is left associative, so
+// `x |> f() |> g()` becomes `g(f(x))`.
+func (p *Parser) parsePipelineExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	right := p.parseExpression(precedence)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	call, ok := right.(*ast.CallExpression)
+	if !ok {
+		p.addError(&ParseError{
+			Pos:    right.Pos(),
+			Actual: tok,
+			Msg:    fmt.Sprintf("cannot pipe into %s, expected a call", right.String()),
+			Code:   ErrInvalidPipeline,
+		})
+		return nil
+	}
+
+	call.Arguments = append([]ast.Expression{left}, call.Arguments...)
+	return call
+}
+
+// parseAssignExpression parses an assignment. Assignment is right
+// associative, so `a = b = 1` assigns 1 to both a and b.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	if target == nil {
+		return nil
+	}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.SelectorExpression:
+		// assignable
+	default:
+		p.addError(&ParseError{
+			Pos:    target.Pos(),
+			Actual: p.curToken,
+			Msg:    fmt.Sprintf("cannot assign to %s", target.String()),
+			Code:   ErrInvalidAssignment,
+		})
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+	if exp.Value == nil {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 
@@ -292,12 +1073,63 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseIfExpression() ast.Expression {
 	exp := &ast.IfExpression{Token: p.curToken}
 
+	var ok bool
+	if exp.Condition, exp.Consequence, ok = p.parseIfBranch(); !ok {
+		return nil
+	}
+
+	for p.peekTokenIs(token.ELSE) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+
+			elseIf := &ast.ElseIf{Token: p.curToken}
+			if elseIf.Condition, elseIf.Consequence, ok = p.parseIfBranch(); !ok {
+				return nil
+			}
+			exp.ElseIfs = append(exp.ElseIfs, elseIf)
+			continue
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Alternative = p.parseBlockStatement()
+		break
+	}
+
+	return exp
+}
+
+// parseIfBranch parses the `(condition) { consequence }` following an 'if'
+func (p *Parser) parseIfBranch() (ast.Expression, *ast.BlockStatement, bool) {
+	if !p.expectPeek(token.LPAREN) {
+		return nil, nil, false
+	}
+
+	p.nextToken()
+	condition := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil, false
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil, nil, false
+	}
+
+	return condition, p.parseBlockStatement(), true
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 
 	p.nextToken()
-	exp.Condition = p.parseExpression(LOWEST)
+	exp.Subject = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
@@ -306,33 +1138,193 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		return nil
 	}
 
-	exp.Consequence = p.parseBlockStatement()
-
-	if p.peekTokenIs(token.ELSE) {
+	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
 
-		if !p.expectPeek(token.LBRACE) {
+		arm := p.parseMatchArm()
+		if arm == nil {
 			return nil
 		}
-		exp.Alternative = p.parseBlockStatement()
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
 	}
+	exp.Rbrace = p.curToken.Pos
 
 	return exp
 }
 
+// parseMatchArm parses a `pattern if guard => body` arm. The body is a single
+// expression, so a body starting with { is a hash literal, not a block; an
+// arm needing statements can use an if expression or call a function.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+		if arm.Guard == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	if arm.Body == nil {
+		return nil
+	}
+
+	return arm
+}
+
+// parsePattern parses the pattern starting at curToken
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if p.curToken.Literal == "_" {
+			return &ast.WildcardPattern{Token: p.curToken}
+		}
+		return &ast.BindingPattern{
+			Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE:
+		return p.parseLiteralPattern()
+	case token.MINUS:
+		if p.peekTokenIs(token.INT) || p.peekTokenIs(token.FLOAT) {
+			return p.parseLiteralPattern()
+		}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+
+	p.invalidPatternError()
+	return nil
+}
+
+func (p *Parser) parseLiteralPattern() ast.Pattern {
+	value := p.prefixParseFns[p.curToken.Type]()
+	if value == nil {
+		return nil
+	}
+
+	return &ast.LiteralPattern{Value: value}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	array := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		el := p.parsePattern()
+		if el == nil {
+			return nil
+		}
+		array.Elements = append(array.Elements, el)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	array.Rbracket = p.curToken.Pos
+
+	return array
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	hash := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		var key ast.Expression
+		switch p.curToken.Type {
+		case token.STRING, token.INT, token.TRUE, token.FALSE:
+			key = p.prefixParseFns[p.curToken.Type]()
+		default:
+			p.invalidPatternError()
+		}
+		if key == nil {
+			return nil
+		}
+
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+
+		hash.Keys = append(hash.Keys, key)
+		hash.Values = append(hash.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken.Pos
+
+	return hash
+}
+
+func (p *Parser) invalidPatternError() {
+	p.addError(&ParseError{
+		Pos:    p.curToken.Pos,
+		Actual: p.curToken,
+		Msg:    fmt.Sprintf("invalid pattern %s", p.curToken.Literal),
+		Code:   ErrInvalidPattern,
+	})
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	depth := p.depth
 
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+
+		// a broken statement may have consumed the closing brace
+		if p.depth < depth {
+			break
+		}
 
 		p.nextToken()
 	}
 
+	block.Rbrace = p.curToken.Pos
+
 	return block
 }
 
@@ -343,15 +1335,74 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	fnLit.Parameters = p.parseFunctionParameters()
+	if !p.parseParameters(fnLit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
+	p.parseFunctionBody(fnLit)
+
+	return fnLit
+}
 
+// parseFunctionBody parses the body of fnLit with curToken on the '{'
+func (p *Parser) parseFunctionBody(fnLit *ast.FunctionLiteral) {
+	// break and continue cannot reach loops outside the function
+	loops := p.loops
+	p.loops = 0
 	fnLit.Body = p.parseBlockStatement()
+	p.loops = loops
+}
 
-	return fnLit
+// parseParameters parses the parameter list of a function literal. The
+// parameters with a default value must follow the ones without, and the
+// rest parameter must come last.
+func (p *Parser) parseParameters(fnLit *ast.FunctionLiteral) bool {
+	fnLit.Parameters = []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			fnLit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			break
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		fnLit.Parameters = append(fnLit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+
+			value := p.parseExpression(LOWEST)
+			if value == nil {
+				return false
+			}
+			fnLit.Defaults = append(fnLit.Defaults, value)
+		} else if len(fnLit.Defaults) > 0 {
+			p.addError(&ParseError{
+				Pos:    ident.Pos(),
+				Actual: p.curToken,
+				Msg:    fmt.Sprintf("parameter %s without default follows parameter with default", ident.Value),
+				Code:   ErrInvalidParameter,
+			})
+			return false
+		}
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return false
+		}
+	}
+
+	return p.expectPeek(token.RPAREN)
 }
 
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
@@ -395,7 +1446,11 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
+	// break and continue cannot reach loops outside the macro
+	loops := p.loops
+	p.loops = 0
 	macroLit.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return macroLit
 }
@@ -403,6 +1458,7 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken.Pos
 
 	return exp
 }
@@ -410,6 +1466,7 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken.Pos
 
 	return array
 }
@@ -441,13 +1498,53 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, nil)
+	}
+
 	p.nextToken()
 
 	exp.Index = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken.Pos
+
+	return exp
+}
+
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression {
+	exp := &ast.SelectorExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+// parseSliceExpression parses the rest of `left[low:high]` with peekToken
+// on the ':'
+func (p *Parser) parseSliceExpression(tok token.Token, left, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+
+	p.nextToken()
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
+
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken.Pos
 
 	return exp
 }
@@ -479,6 +1576,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken.Pos
 
 	return hash
 }
@@ -504,9 +1602,25 @@ func (p *Parser) peekTokenIs(t token.Type) bool {
 }
 
 func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(&ParseError{
+		Pos:      p.peekToken.Pos,
+		Expected: []token.Type{t},
+		Actual:   p.peekToken,
+		Msg: fmt.Sprintf("expected next token to be %s, got %s instead",
+			t, p.peekToken.Type),
+		Code: ErrUnexpectedToken,
+	})
+}
+
+// addError records err unless the parser is already recovering from an
+// earlier error in the same statement
+func (p *Parser) addError(err *ParseError) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	p.errors.Add(err)
 }
 
 func (p *Parser) curPrecedence() uint {
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/dikaeinstein/monkey/ast"
@@ -38,6 +41,168 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	testCases := []struct {
+		input         string
+		expectedNames []string
+		expectedRest  string
+		expected      string
+	}{
+		{"let [a, b] = x;", []string{"a", "b"}, "", "let [a, b] = x;"},
+		{"let [a, ...rest] = f(1)", []string{"a"}, "rest", "let [a, ...rest] = f(1);"},
+		{"let [...rest] = x;", nil, "rest", "let [...rest] = x;"},
+		{"let [] = x;", nil, "", "let [] = x;"},
+		{"let {name, age} = person;", []string{"name", "age"}, "", "let {name, age} = person;"},
+		{"let {a,} = x;", []string{"a"}, "", "let {a} = x;"},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements, got %d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.DestructuringLetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.DestructuringLetStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if len(stmt.Names) != len(tC.expectedNames) {
+			t.Fatalf("wrong number of names. want=%d, got=%d",
+				len(tC.expectedNames), len(stmt.Names))
+		}
+		for i, name := range tC.expectedNames {
+			testIdentifier(t, stmt.Names[i], name)
+		}
+		if tC.expectedRest == "" && stmt.Rest != nil {
+			t.Errorf("stmt.Rest is not nil. got=%s", stmt.Rest)
+		}
+		if tC.expectedRest != "" {
+			testIdentifier(t, stmt.Rest, tC.expectedRest)
+		}
+
+		if stmt.String() != tC.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tC.expected, stmt.String())
+		}
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	testCases := []struct {
+		input        string
+		expectedPath string
+		expectedName string
+		expected     string
+	}{
+		{`import "lib.monkey" as lib;`, "lib.monkey", "lib", `import "lib.monkey" as lib;`},
+		{`import "../a/b.monkey" as b`, "../a/b.monkey", "b", `import "../a/b.monkey" as b;`},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements, got %d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if stmt.Path.Value != tC.expectedPath {
+			t.Errorf("stmt.Path.Value wrong. want=%q, got=%q", tC.expectedPath, stmt.Path.Value)
+		}
+		testIdentifier(t, stmt.Name, tC.expectedName)
+
+		if stmt.String() != tC.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tC.expected, stmt.String())
+		}
+	}
+}
+
+func TestExportStatements(t *testing.T) {
+	testCases := []struct {
+		input         string
+		expectedNames []string
+		expected      string
+	}{
+		{"export let x = 5;", []string{"x"}, "export let x = 5;"},
+		{"export let [a, ...b] = xs", []string{"a", "b"}, "export let [a, ...b] = xs;"},
+		{"export let {a, b} = h", []string{"a", "b"}, "export let {a, b} = h;"},
+		{"export type P { x }", []string{"P"}, "export type P { x }"},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements, got %d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExportStatement. got=%T",
+				program.Statements[0])
+		}
+
+		names := stmt.Names()
+		if len(names) != len(tC.expectedNames) {
+			t.Fatalf("wrong number of names. want=%d, got=%d",
+				len(tC.expectedNames), len(names))
+		}
+		for i, name := range tC.expectedNames {
+			testIdentifier(t, names[i], name)
+		}
+
+		if stmt.String() != tC.expected {
+			t.Errorf("stmt.String() wrong. want=%q, got=%q", tC.expected, stmt.String())
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "main.monkey")
+	err := os.WriteFile(filename, []byte("let x = 1;\nlet y = ;"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	program, err := ParseFile(filename)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements, got %d",
+			len(program.Statements))
+	}
+	if pos := program.Statements[0].Pos(); pos.Filename != filename {
+		t.Errorf("wrong filename. want=%q, got=%q", filename, pos.Filename)
+	}
+
+	expected := filename + ":2:9: no prefix parse function for ; found"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error. want=%q, got=%v", expected, err)
+	}
+
+	_, err = ParseFile(filepath.Join(t.TempDir(), "missing.monkey"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("wrong error. want=%v, got=%v", os.ErrNotExist, err)
+	}
+}
+
 func TestReturnStatemet(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -118,6 +283,82 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	testIntegerLiteral(t, stmt.Expression, 5)
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0Xff", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_FF_FF", 65535},
+		{"9223372036854775807", 9223372036854775807},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		intLit, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if intLit.Value != tC.expected {
+			t.Errorf("intLit.Value not %d. got=%d", tC.expected, intLit.Value)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"0.001", 0.001},
+		{"1e-3", 0.001},
+		{"2.5E2", 250},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program has not enough statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		floatLit, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if floatLit.Value != tC.expected {
+			t.Errorf("floatLit.Value not %g. got=%g", tC.expected, floatLit.Value)
+		}
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	testCases := []struct {
 		input string
@@ -332,6 +573,102 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a[1:b + 1] + a[:2][0]",
+			"((a[1:(b + 1)]) + ((a[:2])[0]))",
+		},
+		{
+			"a[-2:]",
+			"(a[(-2):])",
+		},
+		{
+			"a[:]",
+			"(a[:])",
+		},
+		{
+			"x = y = 1 + 2",
+			"x = y = (1 + 2)",
+		},
+		{
+			"x += a * b == c",
+			"x += ((a * b) == c)",
+		},
+		{
+			"f(x -= 1)",
+			"f(x -= 1)",
+		},
+		{
+			"a[i + 1] = b[0] * 2",
+			"(a[(i + 1)]) = ((b[0]) * 2)",
+		},
+		{
+			`h["k"] += 1`,
+			"(h[k]) += 1",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a == b && c < d || !e",
+			"(((a == b) && (c < d)) || (!e))",
+		},
+		{
+			"x = a || b",
+			"x = (a || b)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a | b & c ^ d",
+			"((a | (b & c)) ^ d)",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"1 << 2 + 3 >> 1",
+			"((1 << 2) + (3 >> 1))",
+		},
+		{
+			"-p.x * q.y.z",
+			"((-(p.x)) * ((q.y).z))",
+		},
+		{
+			"p.move(1, 2).norm()",
+			"((p.move)(1, 2).norm)()",
+		},
+		{
+			"a[0].x",
+			"((a[0]).x)",
+		},
+		{
+			"h.db.port = p.x + 1",
+			"((h.db).port) = ((p.x) + 1)",
+		},
+		{
+			"h.n += 2",
+			"(h.n) += 2",
+		},
+		{
+			"xs |> map(f) |> sum()",
+			"sum(map(xs, f))",
+		},
+		{
+			"a + b |> f(c) == d",
+			"(f((a + b), c) == d)",
+		},
+		{
+			"x = a |> f() || b",
+			"x = (f(a) || b)",
+		},
+		{
+			"a |> p.m(1)",
+			"(p.m)(a, 1)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -426,6 +763,287 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else if (z) { z } else { 0 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if len(exp.ElseIfs) != 2 {
+		t.Fatalf("exp.ElseIfs does not contain 2 branches. got=%d", len(exp.ElseIfs))
+	}
+	testInfixExpression(t, exp.ElseIfs[0].Condition, "x", ">", "y")
+	testIdentifier(t, exp.ElseIfs[1].Condition, "z")
+
+	if exp.Alternative == nil {
+		t.Fatalf("exp.Alternative is nil")
+	}
+
+	expected := "if(x < y) xelse if(x > y) yelse ifz zelse 0"
+	if exp.String() != expected {
+		t.Errorf("exp.String() wrong. want=%q, got=%q", expected, exp.String())
+	}
+}
+
+func TestTryStatement(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f(); } catch (e) { g(e); }", "try f() catch (e) g(e)"},
+		{"try { x } finally { y }", "try x finally y"},
+		{"try { x } catch (err) { y } finally { z };", "try x catch (err) y finally z"},
+		{"throw a + b;", "throw (a + b);"},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements, got %d",
+				len(program.Statements))
+		}
+
+		if program.String() != tC.expected {
+			t.Errorf("program.String() wrong. want=%q, got=%q", tC.expected, program.String())
+		}
+	}
+}
+
+func TestTypeStatement(t *testing.T) {
+	input := `type Point {
+		x, y,
+		fn move(self, dx, dy) { Point(self.x + dx, self.y + dy) }
+		fn norm(self) { self.x * self.x + self.y * self.y },
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements, got %d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.TypeStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.TypeStatement. got=%T",
+			program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Name, "Point")
+
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("wrong number of fields. want=2, got=%d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("wrong number of methods. want=2, got=%d", len(stmt.Methods))
+	}
+	testIdentifier(t, stmt.Methods[0].Name, "move")
+	testIdentifier(t, stmt.Methods[1].Name, "norm")
+
+	params := stmt.Methods[0].Function.Parameters
+	if len(params) != 3 {
+		t.Fatalf("wrong number of parameters. want=3, got=%d", len(params))
+	}
+	testIdentifier(t, params[0], "self")
+
+	expected := "type Point { x, y, fn move(self, dx, dy) Point(((self.x) + dx), ((self.y) + dy)), " +
+		"fn norm(self) (((self.x) * (self.x)) + ((self.y) * (self.y))) }"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", expected, stmt.String())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	testInfixExpression(t, stmt.Condition, "x", "<", "y")
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Fatalf("Statements[1] is not ast.BreakStatement. got=%T",
+			stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"for (let i = 0; i < 10; i + 1) { continue; }", "for (let i = 0; (i < 10); (i + 1)) continue;"},
+		{"for (;;) { break }", "for (; ; ) break;"},
+		{"for (i; ; f(i)) { x }", "for (i; ; f(i)) x"},
+		{"for (; ok;) { }", "for (; ok; ) "},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T",
+				program.Statements[0])
+		}
+		if stmt.String() != tC.expected {
+			t.Errorf("wrong statement. want=%q, got=%q", tC.expected, stmt.String())
+		}
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for (x in [1, 2]) { puts(x) }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "x") {
+		return
+	}
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("wrong iterable. want=%q, got=%q", "[1, 2]", stmt.Iterable.String())
+	}
+	if stmt.Body.String() != "puts(x)" {
+		t.Errorf("wrong body. want=%q, got=%q", "puts(x)", stmt.Body.String())
+	}
+}
+
+func TestLoopTrailingSemicolon(t *testing.T) {
+	inputs := []string{
+		"while (x) { x }; x",
+		"for (let i = 0; i < 3; i += 1) { i }; x",
+		"for (i in xs) { i }; x",
+	}
+
+	for _, input := range inputs {
+		l := lexer.New(input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[1].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[1] is not ast.ExpressionStatement. got=%T",
+				program.Statements[1])
+		}
+		testIdentifier(t, stmt.Expression, "x")
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { }", "match x {  }"},
+		{"match (x) { 1 => a, -2.5 => b, \"s\" => c, true => d }", "match x { 1 => a, (-2.5) => b, s => c, true => d }"},
+		{"match (f(x)) { _ => 0, n if n > 1 => n * 2, }", "match f(x) { _ => 0, n if (n > 1) => (n * 2) }"},
+		{"match (x) { [] => 0, [a, [_, 1]] => a }", "match x { [] => 0, [a, [_, 1]] => a }"},
+		{`match (x) { {} => 0, {"a": 1, 2: {true: b}} => b }`, "match x { {} => 0, {a:1, 2:{true:b}} => b }"},
+		{`match (x) { 1 => {"a": 1}, _ => if (x) { 2 } }`, "match x { 1 => {a:1}, _ => ifx 2 }"},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.MatchExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T",
+				stmt.Expression)
+		}
+
+		if exp.String() != tC.expected {
+			t.Errorf("exp.String() wrong. want=%q, got=%q", tC.expected, exp.String())
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -472,6 +1090,8 @@ func TestFunctionParameterParsing(t *testing.T) {
 		{input: "fn() {};", expectedParams: []string{}},
 		{input: "fn(x) {};", expectedParams: []string{"x"}},
 		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn(x, y = 1, z = y) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn(x, ...rest) {};", expectedParams: []string{"x"}},
 	}
 	for _, tC := range testCases {
 		l := lexer.New(tC.input)
@@ -490,6 +1110,36 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameters(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) { a + b }", "fn(a, b = 10) (a + b)"},
+		{"fn(a = 1, b = a * 2,) { }", "fn(a = 1, b = (a * 2)) "},
+		{"fn(...rest) { rest }", "fn(...rest) rest"},
+		{"fn(a, b = [], ...rest) { }", "fn(a, b = [], ...rest) "},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T",
+				stmt.Expression)
+		}
+
+		if function.String() != tC.expected {
+			t.Errorf("function.String() wrong. want=%q, got=%q", tC.expected, function.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	l := lexer.New(input)
@@ -538,6 +1188,46 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"total: ${price * qty} (${currency})"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. want=5, got=%d", len(str.Parts))
+	}
+
+	literals := map[int]string{0: "total: ", 2: " (", 4: ")"}
+	for i, expected := range literals {
+		lit, ok := str.Parts[i].(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("parts[%d] not *ast.StringLiteral. got=%T", i, str.Parts[i])
+		}
+		if lit.Value != expected {
+			t.Errorf("parts[%d] wrong. want=%q, got=%q", i, expected, lit.Value)
+		}
+	}
+
+	testInfixExpression(t, str.Parts[1], "price", "*", "qty")
+	testIdentifier(t, str.Parts[3], "currency")
+
+	if str.String() != "total: ${(price * qty)} (${currency})" {
+		t.Errorf("wrong String(). got=%q", str.String())
+	}
+	if str.End().Column != len(input)+1 {
+		t.Errorf("wrong End(). want column %d, got=%s", len(input)+1, str.End())
+	}
+}
+
 func TestParsingArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	l := lexer.New(input)
@@ -750,6 +1440,180 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 }
 
+func TestParserErrorPositions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"let = 5;", "1:5: expected next token to be IDENT, got = instead"},
+		{"let x 5;", "1:7: expected next token to be =, got INT instead"},
+		{"1 +\n  );", "2:3: no prefix parse function for ) found"},
+		{"let x = 1; /* unterminated", "1:12: unterminated block comment"},
+		{"let s = \"abc;", "1:9: unterminated string literal"},
+		{"let n = 9223372036854775808;", "1:9: integer literal 9223372036854775808 is out of range"},
+		{"1 + 0xFFFFFFFFFFFFFFFFF", "1:5: integer literal 0xFFFFFFFFFFFFFFFFF is out of range"},
+		{"0b102", `1:1: could not parse "0b102" as integer`},
+		{"1__0", `1:1: could not parse "1__0" as integer`},
+		{`"a ${x y}"`, "1:8: expected } to close interpolated expression, got IDENT instead"},
+		{"break;", "1:1: break outside loop"},
+		{"1 = 2;", "1:1: cannot assign to 1"},
+		{"let y = a + b *= 2;", "1:9: cannot assign to (a + b)"},
+		{"while (x) { fn() { continue; } }", "1:20: continue outside loop"},
+		{"for (x in) { }", "1:10: no prefix parse function for ) found"},
+		{"match (x) { 1 + 2 => 3 }", "1:15: expected next token to be =>, got + instead"},
+		{"match (x) { -a => 1 }", "1:13: invalid pattern -"},
+		{"let [a, ...b, c] = x;", "1:13: expected next token to be ], got , instead"},
+		{"let {...a} = x;", "1:6: expected next token to be IDENT, got ... instead"},
+		{"let [a, 1] = x;", "1:9: expected next token to be IDENT, got INT instead"},
+		{"let [a] x;", "1:9: expected next token to be =, got IDENT instead"},
+		{"fn(a = 1, b) { }", "1:11: parameter b without default follows parameter with default"},
+		{"fn(...a, b) { }", "1:8: expected next token to be ), got , instead"},
+		{"fn(1) { }", "1:4: expected next token to be IDENT, got INT instead"},
+		{"match (x) { {a: 1} => 1 }", "1:14: invalid pattern a"},
+		{"match (x) { 1 => 2 3 => 4 }", "1:20: expected next token to be ,, got INT instead"},
+		{"match (x) { 1 => { 1; 2 } }", "1:21: expected next token to be :, got ; instead"},
+		{"match (x) { 1 => { let y = 2; y } }", "1:20: no prefix parse function for LET found"},
+		{`if (x) { import "a" as a }`, "1:10: import outside top level"},
+		{"fn() { export let a = 1; }", "1:8: export outside top level"},
+		{"import a as b", "1:8: expected next token to be STRING, got IDENT instead"},
+		{`import "a" b`, "1:12: expected next token to be AS, got IDENT instead"},
+		{"export 1", "1:8: expected next token to be LET, got INT instead"},
+		{"try { } x", "1:9: expected next token to be CATCH or FINALLY, got IDENT instead"},
+		{"try { } catch e { }", "1:15: expected next token to be (, got IDENT instead"},
+		{"throw;", "1:6: no prefix parse function for ; found"},
+		{"type P { x y }", "1:12: expected next token to be ,, got IDENT instead"},
+		{"type P { x, fn x(self) { } }", "1:16: duplicate member x in type P"},
+		{"type P { fn m() { } }", "1:13: method m has no parameter for the receiver"},
+		{"p.1", "1:3: expected next token to be IDENT, got INT instead"},
+		{"xs |> f", "1:7: cannot pipe into f, expected a call"},
+		{"xs |> f() + 1", "1:7: cannot pipe into (f() + 1), expected a call"},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("expected parser errors for %q, got none", tC.input)
+		}
+		if errors[0].Error() != tC.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tC.expected, errors[0])
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	testCases := []struct {
+		input          string
+		expectedErrors []string
+		expectedOutput string
+	}{
+		{
+			"let = 5; let x 5; let y = 10;",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"1:16: expected next token to be =, got INT instead",
+			},
+			"let y = 10;",
+		},
+		{
+			"let x = 5\nlet y = ;\nlet z = 3;",
+			[]string{"2:9: no prefix parse function for ; found"},
+			"let x = 5;let z = 3;",
+		},
+		{
+			"let f = fn() { let = 1; x + ; return 3; }; f();",
+			[]string{
+				"1:20: expected next token to be IDENT, got = instead",
+				"1:29: no prefix parse function for ; found",
+			},
+			"let f = fn<f>() return 3;;f()",
+		},
+		{
+			"let f = fn() { x + }; let z = 1;",
+			[]string{"1:20: no prefix parse function for } found"},
+			"let f = fn<f>() ;let z = 1;",
+		},
+		{
+			"if (x { y } ; let q = 1;",
+			[]string{"1:7: expected next token to be ), got { instead"},
+			"let q = 1;",
+		},
+		{
+			"while (x) { let = 1; break; } let q = 1;",
+			[]string{"1:17: expected next token to be IDENT, got = instead"},
+			"while (x) break;let q = 1;",
+		},
+	}
+
+	for _, tC := range testCases {
+		l := lexer.New(tC.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors().Strings()
+		if len(errors) != len(tC.expectedErrors) {
+			t.Fatalf("wrong number of errors for %q. want=%q, got=%q",
+				tC.input, tC.expectedErrors, errors)
+		}
+		for i, msg := range errors {
+			if msg != tC.expectedErrors[i] {
+				t.Errorf("wrong error. want=%q, got=%q", tC.expectedErrors[i], msg)
+			}
+		}
+
+		for i, stmt := range program.Statements {
+			if stmt == nil {
+				t.Fatalf("program.Statements[%d] is nil", i)
+			}
+		}
+		if program.String() != tC.expectedOutput {
+			t.Errorf("wrong program. want=%q, got=%q", tC.expectedOutput, program.String())
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y;
+};
+add(1, [2, 3][0]);`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	fn := letStmt.Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+
+	testCases := []struct {
+		node  ast.Node
+		start string
+		end   string
+	}{
+		{program, "1:1", "4:18"},
+		{letStmt, "1:1", "3:2"},
+		{fn, "1:11", "3:2"},
+		{body.Expression, "2:3", "2:8"},
+		{call, "4:1", "4:18"},
+		{call.Arguments[1], "4:8", "4:17"},
+	}
+
+	for _, tC := range testCases {
+		if tC.node.Pos().String() != tC.start {
+			t.Errorf("%s: wrong Pos. want=%s, got=%s", tC.node, tC.start, tC.node.Pos())
+		}
+		if tC.node.End().String() != tC.end {
+			t.Errorf("%s: wrong End. want=%s, got=%s", tC.node, tC.end, tC.node.End())
+		}
+	}
+}
+
 func testLetStatements(t *testing.T, stmt ast.Statement, name string, expectedValue interface{}) bool {
 	t.Helper()
 
@@ -790,8 +1654,8 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("parser has had %d errors", len(errors))
-	for _, msg := range p.errors {
-		t.Errorf("parser error: %q", msg)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err)
 	}
 	t.FailNow()
 }
@@ -57,7 +57,7 @@ func Start(in io.Reader, out io.Writer) {
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != allowedNumOfErrors {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, p.Errors().Strings())
 			continue
 		}
 
@@ -65,6 +65,7 @@ func Start(in io.Reader, out io.Writer) {
 		expanded := eval.ExpandMacros(program, macroEnv)
 
 		compiler := compile.New(symbolTable, constants)
+		compiler.SetMacroEvaluator(eval.EvalMacro)
 		err := compiler.Compile(expanded)
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
@@ -2,6 +2,8 @@ package test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 
 	"github.com/dikaeinstein/monkey/ast"
 	"github.com/dikaeinstein/monkey/lexer"
@@ -29,3 +31,19 @@ func IntegerObject(expected int64, actual object.Object) error {
 
 	return nil
 }
+
+// WriteFiles writes files, which maps slash-separated paths relative to dir
+// to their contents, creating directories as needed
+func WriteFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,5 +1,10 @@
 package token
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Type is the set of lexical token types of the Monkey programming language.
 type Type string
 
@@ -7,6 +12,63 @@ type Type string
 type Token struct {
 	Type
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the token
+
+	// Comments holds the comments preceding the token. It is only set when
+	// the lexer was asked to retain comments.
+	Comments *CommentGroup
+}
+
+// Comment is a single // or /* */ comment, including its delimiters
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
+}
+
+// CommentGroup is a sequence of comments with no other tokens in between
+type CommentGroup struct {
+	List []Comment
+}
+
+// Text returns the text of the comments in the group separated by newlines
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+
+	lines := make([]string, len(g.List))
+	for i, c := range g.List {
+		lines[i] = c.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Position describes a location in the source code.
+// Line and Column are 1-based, Column counts runes rather than bytes.
+// Offset is the 0-based byte offset. Filename is empty unless the source
+// was read from a file.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position in the form "file:line:column", or
+// "line:column" if there is no filename, or "-" if the position is not valid.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
@@ -16,7 +78,11 @@ const (
 	// Identifiers + literals
 	IDENT  Type = "IDENT" // add, foobar, x, y, ...
 	INT    Type = "INT"
+	FLOAT  Type = "FLOAT"
 	STRING Type = "STRING"
+	// INTERPOLATION is the part of a string literal before a ${...}
+	// expression, e.g. "total: " in "total: ${price * qty}"
+	INTERPOLATION Type = "INTERPOLATION"
 
 	// Operators
 	ASSIGN   Type = "="
@@ -25,11 +91,33 @@ const (
 	BANG     Type = "!"
 	ASTERISK Type = "*"
 	SLASH    Type = "/"
+	PERCENT  Type = "%"
 	LT       Type = "<"
 	GT       Type = ">"
+	LtEQ     Type = "<="
+	GtEQ     Type = ">="
 	EQ       Type = "=="
 	NotEQ    Type = "!="
 
+	AMPERSAND  Type = "&"
+	PIPE       Type = "|"
+	CARET      Type = "^"
+	ShiftLeft  Type = "<<"
+	ShiftRight Type = ">>"
+
+	PlusAssign     Type = "+="
+	MinusAssign    Type = "-="
+	AsteriskAssign Type = "*="
+	SlashAssign    Type = "/="
+
+	AND Type = "&&"
+	OR  Type = "||"
+
+	ARROW    Type = "=>"
+	PIPELINE Type = "|>"
+	ELLIPSIS Type = "..."
+	DOT      Type = "."
+
 	// Delimiters
 	COMMA     Type = ","
 	SEMICOLON Type = ";"
@@ -50,17 +138,45 @@ const (
 	FALSE    Type = "FALSE"
 	TRUE     Type = "TRUE"
 	MACRO    Type = "MACRO"
+	WHILE    Type = "WHILE"
+	FOR      Type = "FOR"
+	IN       Type = "IN"
+	BREAK    Type = "BREAK"
+	CONTINUE Type = "CONTINUE"
+	MATCH    Type = "MATCH"
+	IMPORT   Type = "IMPORT"
+	EXPORT   Type = "EXPORT"
+	AS       Type = "AS"
+	TRY      Type = "TRY"
+	CATCH    Type = "CATCH"
+	FINALLY  Type = "FINALLY"
+	THROW    Type = "THROW"
+	TYPE     Type = "TYPE"
 )
 
 var keywords = map[string]Type{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
-	"macro":  MACRO,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"macro":    MACRO,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"match":    MATCH,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"type":     TYPE,
 }
 
 // LookupIdent returns the appropriate keyword token type or IDENT
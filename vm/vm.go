@@ -2,6 +2,8 @@ package vm
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/dikaeinstein/monkey/code"
 	"github.com/dikaeinstein/monkey/compile"
@@ -22,6 +24,27 @@ type VM struct {
 
 	stack []object.Object
 	sp    uint // Always points to the next value. Top of stack is stack[sp-1]
+
+	// handlers holds the exception handlers installed by OpTry, innermost
+	// last
+	handlers []handler
+}
+
+// handler is an exception handler. It resumes execution at ip in the frame
+// it was installed in, with the stack as it was then plus the caught value.
+type handler struct {
+	ip          int
+	framesIndex int
+	sp          uint
+}
+
+// exception is the error produced by OpThrow
+type exception struct {
+	value object.Object
+}
+
+func (e *exception) Error() string {
+	return fmt.Sprintf("uncaught exception: %s", e.value.Inspect())
 }
 
 func NewWithGlobalsStore(bytecode *compile.Bytecode, globals []object.Object) *VM {
@@ -65,9 +88,44 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
 }
 
-//gocyclo:ignore
-// Run fetches, decodes and executes the bytecode instructions
+// Run fetches, decodes and executes the bytecode instructions. A thrown value
+// or an error is passed to the innermost exception handler, as the error
+// message for an error, and execution resumes there. Run returns the error
+// if there is no handler.
 func (vm *VM) Run() error {
+	for {
+		err := vm.run()
+		if err == nil {
+			return nil
+		}
+
+		if len(vm.handlers) == 0 {
+			return err
+		}
+		vm.catch(err)
+	}
+}
+
+// catch passes the value thrown, or the message of err, to the innermost
+// exception handler, unwinding the frames and stack above it
+func (vm *VM) catch(err error) {
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	var caught object.Object = object.String(err.Error())
+	if e, ok := err.(*exception); ok {
+		caught = e.value
+	}
+
+	vm.framesIndex = h.framesIndex
+	vm.currentFrame().ip = h.ip - 1
+	vm.sp = h.sp
+	vm.stack[vm.sp] = caught
+	vm.sp++
+}
+
+//gocyclo:ignore
+func (vm *VM) run() error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
@@ -90,7 +148,8 @@ func (vm *VM) Run() error {
 			}
 		case code.OpPop:
 			vm.pop()
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
@@ -105,7 +164,7 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanOrEqual:
 			err := vm.executeComparison(op)
 			if err != nil {
 				return err
@@ -157,6 +216,45 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpInterpolate:
+			numOfParts := uint(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += code.OperandWidth2
+
+			str := vm.buildString(vm.sp-numOfParts, vm.sp)
+			vm.sp -= numOfParts
+
+			err := vm.push(str)
+			if err != nil {
+				return err
+			}
+		case code.OpGetIter:
+			iterable := vm.pop()
+			it, ok := object.NewIterator(iterable)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", iterable.Type())
+			}
+
+			err := vm.push(it)
+			if err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += code.OperandWidth2
+
+			it := vm.pop().(*object.Iterator)
+			element, ok := it.Next()
+			if !ok {
+				// leave null rather than the iterator as the last popped value
+				vm.stack[vm.sp] = object.NullValue()
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+
+			err := vm.push(element)
+			if err != nil {
+				return err
+			}
 		case code.OpHash:
 			numOfElements := uint(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -172,6 +270,51 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpModule:
+			numOfElements := uint(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			module := vm.buildModule(vm.sp-numOfElements-1, vm.sp)
+
+			vm.sp -= numOfElements + 1
+			err := vm.push(module)
+			if err != nil {
+				return err
+			}
+		case code.OpRecordType:
+			numFields := uint(code.ReadUint16(ins[ip+1:]))
+			numMethods := uint(code.ReadUint16(ins[ip+3:]))
+			vm.currentFrame().ip += 4
+
+			startIndex := vm.sp - numFields - 2*numMethods - 1
+			recordType := vm.buildRecordType(startIndex, numFields, vm.sp)
+
+			vm.sp = startIndex
+			err := vm.push(recordType)
+			if err != nil {
+				return err
+			}
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += code.OperandWidth2
+
+			vm.handlers = append(vm.handlers, handler{
+				ip:          pos,
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+			})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpStackDepth:
+			err := vm.push(object.Integer(vm.sp))
+			if err != nil {
+				return err
+			}
+		case code.OpResetStack:
+			// drops the operands left by the expressions a loop jump leaves
+			vm.sp = uint(vm.pop().(object.Integer))
+		case code.OpThrow:
+			return &exception{value: vm.pop()}
 		case code.OpIndex:
 			index := vm.pop()
 			left := vm.pop()
@@ -180,6 +323,77 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSliceExpression(left, low, high)
+			if err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSetIndex(left, index, value)
+			if err != nil {
+				return err
+			}
+		case code.OpMatchArray:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += code.OperandWidth2
+
+			array, ok := vm.pop().(*object.Array)
+			err := vm.push(object.Boolean(ok && len(array.Elements) == n))
+			if err != nil {
+				return err
+			}
+		case code.OpMatchHash:
+			_, ok := vm.pop().(*object.Hash)
+			err := vm.push(object.Boolean(ok))
+			if err != nil {
+				return err
+			}
+		case code.OpMatchKey:
+			key, hashable := object.IsHashable(vm.pop())
+			hash, ok := vm.pop().(*object.Hash)
+			if ok && hashable {
+				_, ok = hash.Pairs[key]
+			}
+
+			err := vm.push(object.Boolean(ok && hashable))
+			if err != nil {
+				return err
+			}
+		case code.OpDestructureArray:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			hasRest := code.ReadUint8(ins[ip+3:]) == 1
+			vm.currentFrame().ip += (code.OperandWidth2 + code.OperandWidth1)
+
+			err := vm.executeDestructureArray(n, hasRest)
+			if err != nil {
+				return err
+			}
+		case code.OpDestructureHash:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += code.OperandWidth2
+
+			err := vm.executeDestructureHash(n)
+			if err != nil {
+				return err
+			}
+		case code.OpDup:
+			n := uint(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip++
+
+			for _, obj := range vm.stack[vm.sp-n : vm.sp] {
+				err := vm.push(obj)
+				if err != nil {
+					return err
+				}
+			}
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
@@ -211,13 +425,18 @@ func (vm *VM) Run() error {
 			vm.currentFrame().ip += code.OperandWidth1
 
 			frame := vm.currentFrame()
-			vm.stack[frame.basePointer+uint(localIdx)] = vm.pop()
+			slot := &vm.stack[frame.basePointer+uint(localIdx)]
+			if cell, ok := (*slot).(*object.Cell); ok {
+				cell.Value = vm.pop()
+			} else {
+				*slot = vm.pop()
+			}
 		case code.OpGetLocal:
 			localIdx := code.ReadUint8(ins[vm.currentFrame().ip+1:])
 			vm.currentFrame().ip += code.OperandWidth1
 
 			frame := vm.currentFrame()
-			err := vm.push(vm.stack[frame.basePointer+uint(localIdx)])
+			err := vm.push(deref(vm.stack[frame.basePointer+uint(localIdx)]))
 			if err != nil {
 				return err
 			}
@@ -247,7 +466,41 @@ func (vm *VM) Run() error {
 
 			currentClosure := vm.currentFrame().cl
 
-			err := vm.push(currentClosure.Free[freeIndex])
+			err := vm.push(deref(currentClosure.Free[freeIndex]))
+			if err != nil {
+				return err
+			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			cell, ok := vm.currentFrame().cl.Free[freeIndex].(*object.Cell)
+			if !ok {
+				return fmt.Errorf("cannot assign to free variable %d", freeIndex)
+			}
+			cell.Value = vm.pop()
+		case code.OpCaptureLocal:
+			localIdx := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			// the first capture moves the local into a cell that the
+			// frame and every closure capturing it share from then on
+			slot := &vm.stack[vm.currentFrame().basePointer+uint(localIdx)]
+			cell, ok := (*slot).(*object.Cell)
+			if !ok {
+				cell = &object.Cell{Value: *slot}
+				*slot = cell
+			}
+
+			err := vm.push(cell)
+			if err != nil {
+				return err
+			}
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			err := vm.push(vm.currentFrame().cl.Free[freeIndex])
 			if err != nil {
 				return err
 			}
@@ -288,6 +541,16 @@ func (vm *VM) buildArray(startIndex, endIndex uint) object.Object {
 	return &object.Array{Elements: elements}
 }
 
+// buildString concatenates the Inspect of the objects in the given stack range
+func (vm *VM) buildString(startIndex, endIndex uint) object.Object {
+	var out strings.Builder
+	for i := startIndex; i < endIndex; i++ {
+		out.WriteString(vm.stack[i].Inspect())
+	}
+
+	return object.String(out.String())
+}
+
 func (vm *VM) buildHash(startIndex, endIndex uint) (object.Object, error) {
 	pairs := make(map[object.String]object.Object)
 
@@ -306,6 +569,43 @@ func (vm *VM) buildHash(startIndex, endIndex uint) (object.Object, error) {
 	return &object.Hash{Pairs: pairs}, nil
 }
 
+// buildModule builds a module from its name at startIndex followed by the
+// names and values of its exports
+func (vm *VM) buildModule(startIndex, endIndex uint) object.Object {
+	module := &object.Module{
+		Name:    string(vm.stack[startIndex].(object.String)),
+		Exports: make(map[string]object.Object),
+	}
+
+	for i := startIndex + 1; i < endIndex; i += 2 {
+		name := vm.stack[i].(object.String)
+		module.Exports[string(name)] = vm.stack[i+1]
+	}
+
+	return module
+}
+
+// buildRecordType builds a record type from its name at startIndex followed
+// by the names of its numFields fields and the names and closures of its
+// methods
+func (vm *VM) buildRecordType(startIndex, numFields, endIndex uint) object.Object {
+	recordType := &object.RecordType{
+		Name:    string(vm.stack[startIndex].(object.String)),
+		Fields:  make([]string, numFields),
+		Methods: make(map[string]object.Object),
+	}
+
+	for i := uint(0); i < numFields; i++ {
+		recordType.Fields[i] = string(vm.stack[startIndex+1+i].(object.String))
+	}
+	for i := startIndex + 1 + numFields; i < endIndex; i += 2 {
+		name := vm.stack[i].(object.String)
+		recordType.Methods[string(name)] = vm.stack[i+1]
+	}
+
+	return recordType
+}
+
 func (vm *VM) push(obj object.Object) error {
 	if vm.sp > StackSize {
 		return fmt.Errorf("stack overflow")
@@ -349,6 +649,8 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	switch {
 	case leftType == object.INTEGER && rightType == object.INTEGER:
 		return vm.executeBinaryIntegerOperation(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return vm.executeBinaryFloatOperation(op, left, right)
 	case leftType == object.STRING && rightType == object.STRING:
 		return vm.executeBinaryStringOperation(op, left, right)
 	}
@@ -372,8 +674,30 @@ func (vm *VM) executeBinaryIntegerOperation(
 		result = leftValue - rightValue
 	case code.OpMul:
 		result = leftValue * rightValue
-	case code.OpDiv:
-		result = leftValue / rightValue
+	case code.OpDiv, code.OpMod:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		if op == code.OpMod {
+			result = leftValue % rightValue
+		} else {
+			result = leftValue / rightValue
+		}
+	case code.OpBitAnd:
+		result = leftValue & rightValue
+	case code.OpBitOr:
+		result = leftValue | rightValue
+	case code.OpBitXor:
+		result = leftValue ^ rightValue
+	case code.OpShiftLeft, code.OpShiftRight:
+		if rightValue < 0 {
+			return fmt.Errorf("negative shift count: %d", rightValue)
+		}
+		if op == code.OpShiftLeft {
+			result = leftValue << rightValue
+		} else {
+			result = leftValue >> rightValue
+		}
 	default:
 		return fmt.Errorf("unknown integer operator: %d", op)
 	}
@@ -381,6 +705,34 @@ func (vm *VM) executeBinaryIntegerOperation(
 	return vm.push(result)
 }
 
+// executeBinaryFloatOperation executes a binary operation where at least one
+// operand is a float; an integer operand is converted to a float
+func (vm *VM) executeBinaryFloatOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue, _ := object.AsFloat(left)
+	rightValue, _ := object.AsFloat(right)
+
+	var result float64
+	switch op {
+	case code.OpAdd:
+		result = leftValue + rightValue
+	case code.OpSub:
+		result = leftValue - rightValue
+	case code.OpMul:
+		result = leftValue * rightValue
+	case code.OpDiv:
+		result = leftValue / rightValue
+	case code.OpMod:
+		result = math.Mod(leftValue, rightValue)
+	default:
+		return fmt.Errorf("unknown float operator: %d", op)
+	}
+
+	return vm.push(object.Float(result))
+}
+
 func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Object) error {
 	if op != code.OpAdd {
 		return fmt.Errorf("unknown string operator: %d", op)
@@ -399,6 +751,15 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	if left.Type() == object.INTEGER && right.Type() == object.INTEGER {
 		return vm.executeIntegerComparison(op, left, right)
 	}
+	if isNumber(left) && isNumber(right) {
+		return vm.executeFloatComparison(op, left, right)
+	}
+	if left.Type() == object.STRING && right.Type() == object.STRING {
+		return vm.executeStringComparison(op, left, right)
+	}
+	if left.Type() == object.RECORD && right.Type() == object.RECORD {
+		return vm.executeRecordComparison(op, left, right)
+	}
 
 	switch op {
 	case code.OpEqual:
@@ -411,6 +772,23 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	}
 }
 
+func (vm *VM) executeRecordComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	equal := left.(*object.Record).Equal(right.(*object.Record))
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(object.Boolean(equal))
+	case code.OpNotEqual:
+		return vm.push(object.Boolean(!equal))
+	default:
+		return fmt.Errorf("unknown operator: %d (%s %s)",
+			op, left.Type(), right.Type())
+	}
+}
+
 func (vm *VM) executeIntegerComparison(
 	op code.Opcode,
 	left, right object.Object,
@@ -425,6 +803,52 @@ func (vm *VM) executeIntegerComparison(
 		return vm.push(object.Boolean(rightValue != leftValue))
 	case code.OpGreaterThan:
 		return vm.push(object.Boolean(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(object.Boolean(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+func (vm *VM) executeFloatComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue, _ := object.AsFloat(left)
+	rightValue, _ := object.AsFloat(right)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(object.Boolean(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(object.Boolean(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(object.Boolean(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(object.Boolean(leftValue >= rightValue))
+	default:
+		return fmt.Errorf("unknown operator: %d", op)
+	}
+}
+
+// executeStringComparison compares strings byte-wise, in the same order as
+// their UTF-8 encodings
+func (vm *VM) executeStringComparison(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	leftValue := left.(object.String)
+	rightValue := right.(object.String)
+
+	switch op {
+	case code.OpEqual:
+		return vm.push(object.Boolean(rightValue == leftValue))
+	case code.OpNotEqual:
+		return vm.push(object.Boolean(rightValue != leftValue))
+	case code.OpGreaterThan:
+		return vm.push(object.Boolean(leftValue > rightValue))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(object.Boolean(leftValue >= rightValue))
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
@@ -439,6 +863,8 @@ func (vm *VM) executePrefixExpression(op code.Opcode) error {
 		switch operand := operand.(type) {
 		case object.Integer:
 			return vm.push(-operand)
+		case object.Float:
+			return vm.push(-operand)
 		default:
 			return fmt.Errorf("unsupported type for prefix expression: %s",
 				operand.Type())
@@ -467,8 +893,14 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING && index.Type() == object.INTEGER:
+		return vm.executeStringIndex(left, index)
 	case left.Type() == object.HASH:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.MODULE && index.Type() == object.STRING:
+		return vm.executeModuleIndex(left, index)
+	case left.Type() == object.RECORD && index.Type() == object.STRING:
+		return vm.executeRecordIndex(left, index)
 	default:
 		return fmt.Errorf("index operator not supported: %s", left.Type())
 	}
@@ -486,6 +918,78 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	return vm.push(arrayObject.Elements[i])
 }
 
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	ch, ok := str.(object.String).At(int64(index.(object.Integer)))
+	if !ok {
+		return vm.push(object.NullValue())
+	}
+
+	return vm.push(ch)
+}
+
+func (vm *VM) executeModuleIndex(left, index object.Object) error {
+	module := left.(*object.Module)
+	val, ok := module.Exports[string(index.(object.String))]
+	if !ok {
+		return fmt.Errorf("%s has no export %s", module.Name, index.Inspect())
+	}
+
+	return vm.push(val)
+}
+
+func (vm *VM) executeRecordIndex(left, index object.Object) error {
+	record := left.(*object.Record)
+	val, ok := record.Get(string(index.(object.String)))
+	if !ok {
+		return fmt.Errorf("%s has no field %s", record.RecordType.Name, index.Inspect())
+	}
+
+	return vm.push(val)
+}
+
+func (vm *VM) executeSliceExpression(left, low, high object.Object) error {
+	var length int64
+	switch left := left.(type) {
+	case *object.Array:
+		length = int64(len(left.Elements))
+	case object.String:
+		length = int64(left.Len())
+	default:
+		return fmt.Errorf("slice operator not supported: %s", left.Type())
+	}
+
+	lo, err := sliceBound(low, 0)
+	if err != nil {
+		return err
+	}
+	hi, err := sliceBound(high, length)
+	if err != nil {
+		return err
+	}
+	lo, hi = object.SliceBounds(length, lo, hi)
+
+	if array, ok := left.(*object.Array); ok {
+		elements := make([]object.Object, hi-lo)
+		copy(elements, array.Elements[lo:hi])
+		return vm.push(&object.Array{Elements: elements})
+	}
+
+	return vm.push(left.(object.String).Slice(lo, hi))
+}
+
+// sliceBound returns the integer value of a slice bound, or def if the bound
+// was left out
+func sliceBound(bound object.Object, def int64) (int64, error) {
+	switch bound := bound.(type) {
+	case *object.Null:
+		return def, nil
+	case object.Integer:
+		return int64(bound), nil
+	default:
+		return 0, fmt.Errorf("slice index must be INTEGER, got %s", bound.Type())
+	}
+}
+
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	hashObject := hash.(*object.Hash)
 
@@ -502,6 +1006,108 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	return vm.push(value)
 }
 
+// executeDestructureArray replaces the array on top of the stack with its
+// first n elements, followed by an array of the remaining ones if hasRest
+// is set. The first element ends up on top of the stack.
+func (vm *VM) executeDestructureArray(n int, hasRest bool) error {
+	val := vm.pop()
+	array, ok := val.(*object.Array)
+	if !ok {
+		return fmt.Errorf("cannot destructure %s as ARRAY", val.Type())
+	}
+
+	switch {
+	case !hasRest && len(array.Elements) != n:
+		return fmt.Errorf("wrong number of elements to destructure: want=%d, got=%d",
+			n, len(array.Elements))
+	case len(array.Elements) < n:
+		return fmt.Errorf("not enough elements to destructure: want at least %d, got %d",
+			n, len(array.Elements))
+	}
+
+	if hasRest {
+		rest := make([]object.Object, len(array.Elements)-n)
+		copy(rest, array.Elements[n:])
+
+		err := vm.push(&object.Array{Elements: rest})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		err := vm.push(array.Elements[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// executeDestructureHash replaces the hash and the n keys on top of the
+// stack with the values of those keys, the value of the first key on top
+func (vm *VM) executeDestructureHash(n int) error {
+	keys := make([]object.Object, n)
+	for i := n - 1; i >= 0; i-- {
+		keys[i] = vm.pop()
+	}
+
+	val := vm.pop()
+	hash, ok := val.(*object.Hash)
+	if !ok {
+		return fmt.Errorf("cannot destructure %s as HASH", val.Type())
+	}
+
+	values := make([]object.Object, n)
+	for i, key := range keys {
+		k, _ := object.IsHashable(key)
+		v, ok := hash.Pairs[k]
+		if !ok {
+			return fmt.Errorf("cannot destructure missing key: %s", k)
+		}
+		values[i] = v
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		err := vm.push(values[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// executeSetIndex stores value at index in an array or hash and pushes the
+// value as the result of the assignment
+func (vm *VM) executeSetIndex(left, index, value object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
+		array := left.(*object.Array)
+		i := int64(index.(object.Integer))
+		if i < 0 || i >= int64(len(array.Elements)) {
+			return fmt.Errorf("index out of range: %d (length %d)", i, len(array.Elements))
+		}
+		array.Elements[i] = value
+	case left.Type() == object.HASH:
+		key, ok := object.IsHashable(index)
+		if !ok {
+			return fmt.Errorf("unusable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Pairs[key] = value
+	case left.Type() == object.RECORD && index.Type() == object.STRING:
+		record := left.(*object.Record)
+		if !record.Set(string(index.(object.String)), value) {
+			return fmt.Errorf("%s has no field %s", record.RecordType.Name, index.Inspect())
+		}
+	default:
+		return fmt.Errorf("index assignment not supported: %s", left.Type())
+	}
+
+	return vm.push(value)
+}
+
 func (vm *VM) executeCall(numArgs uint8) error {
 	callee := vm.stack[vm.sp-1-uint(numArgs)]
 
@@ -510,20 +1116,83 @@ func (vm *VM) executeCall(numArgs uint8) error {
 		return vm.callClosure(callee, numArgs)
 	case object.BuiltInFunction:
 		return vm.callBuiltinFn(callee, numArgs)
+	case *object.RecordType:
+		return vm.callRecordType(callee, numArgs)
+	case *object.BoundMethod:
+		return vm.callMethod(callee, numArgs)
 	default:
 		return fmt.Errorf("calling non-function and non-built-in")
 	}
 }
 
-func (vm *VM) callClosure(cl *object.Closure, numArgs uint8) error {
-	if numArgs != uint8(cl.Fn.NumParameters) {
+// callRecordType replaces the record type and the field values on the
+// stack with a new record
+func (vm *VM) callRecordType(recordType *object.RecordType, numArgs uint8) error {
+	if int(numArgs) != len(recordType.Fields) {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
-			cl.Fn.NumParameters, numArgs)
+			len(recordType.Fields), numArgs)
+	}
+
+	record := object.NewRecord(recordType, vm.stack[vm.sp-uint(numArgs):vm.sp])
+	vm.sp = vm.sp - uint(numArgs) - 1
+
+	return vm.push(record)
+}
+
+// callMethod calls the method's closure with the receiver inserted before
+// the arguments
+func (vm *VM) callMethod(method *object.BoundMethod, numArgs uint8) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("stack overflow")
 	}
 
-	frame := NewFrame(cl, vm.sp-uint(numArgs))
+	callee := vm.sp - 1 - uint(numArgs)
+	copy(vm.stack[callee+2:vm.sp+1], vm.stack[callee+1:vm.sp])
+	vm.stack[callee] = method.Method
+	vm.stack[callee+1] = method.Receiver
+	vm.sp++
+
+	return vm.callClosure(method.Method.(*object.Closure), numArgs+1)
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs uint8) error {
+	fn := cl.Fn
+	n := int(numArgs)
+	required := fn.NumParameters - fn.NumDefaults
+	if n < required || (n > fn.NumParameters && !fn.Variadic) {
+		return fmt.Errorf("wrong number of arguments: want=%s, got=%d",
+			object.FormatArity(required, fn.NumDefaults, fn.Variadic), numArgs)
+	}
+
+	basePointer := vm.sp - uint(numArgs)
+
+	var rest *object.Array
+	if fn.Variadic {
+		rest = &object.Array{Elements: []object.Object{}}
+		if n > fn.NumParameters {
+			extra := vm.stack[basePointer+uint(fn.NumParameters) : vm.sp]
+			rest.Elements = append(rest.Elements, extra...)
+			n = fn.NumParameters
+		}
+	}
+
+	frame := NewFrame(cl, basePointer)
+	if fn.NumDefaults > 0 {
+		// skip the default values of the parameters that were passed
+		frame.ip = fn.DefaultEntries[n-required] - 1
+	}
 	vm.pushFrame(frame)
-	vm.sp = frame.basePointer + uint(cl.Fn.NumLocals)
+	vm.sp = frame.basePointer + uint(fn.NumLocals)
+
+	// clear the locals so that a cell left on the stack by an earlier call
+	// is not mistaken for a captured variable of this one
+	locals := vm.stack[frame.basePointer+uint(n) : vm.sp]
+	for i := range locals {
+		locals[i] = nil
+	}
+	if rest != nil {
+		vm.stack[frame.basePointer+uint(fn.NumParameters)] = rest
+	}
 
 	return nil
 }
@@ -532,6 +1201,8 @@ func (vm *VM) callBuiltinFn(fn object.BuiltInFunction, numArgs uint8) error {
 	args := vm.stack[vm.sp-uint(numArgs) : vm.sp]
 
 	result := fn(args...)
+	// pop the arguments and the builtin itself, like a closure's return does
+	vm.sp = vm.sp - uint(numArgs) - 1
 
 	var err error
 	if result != nil {
@@ -543,6 +1214,15 @@ func (vm *VM) callBuiltinFn(fn object.BuiltInFunction, numArgs uint8) error {
 	return err
 }
 
+// deref returns the value held by obj if it is a cell, or obj itself
+func deref(obj object.Object) object.Object {
+	if cell, ok := obj.(*object.Cell); ok {
+		return cell.Value
+	}
+
+	return obj
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj := obj.(type) {
 	case object.Boolean:
@@ -555,3 +1235,8 @@ func isTruthy(obj object.Object) bool {
 		return true
 	}
 }
+
+func isNumber(obj object.Object) bool {
+	_, ok := object.AsFloat(obj)
+	return ok
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/dikaeinstein/monkey/compile"
+	"github.com/dikaeinstein/monkey/eval"
 	"github.com/dikaeinstein/monkey/object"
 	"github.com/dikaeinstein/monkey/test"
 )
@@ -32,6 +33,48 @@ func TestIntegerArithmetic(t *testing.T) {
 		{"-10", -10},
 		{"-50 + 100 + -50", 0},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"1 + 6 & 3", 3},
+		{"10 - 2 * 3 % 4", 8},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{"1 / 0", "division by zero"},
+		{"5 % (1 - 1)", "division by zero"},
+		{"1 << -1", "negative shift count: -1"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	testCases := []vmTestCase{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 * 2", 3.0},
+		{"2 * 1.5", 3.0},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"10 - 0.5", 9.5},
+		{"1e3 + 1", 1001.0},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"1 != 1.5", true},
+		{"float(3)", 3.0},
+		{"int(2.9)", 2},
+		{`int("42")`, 42},
+		{`int("abc")`, object.Error("could not convert \"abc\" to INTEGER")},
 	}
 
 	runVMTests(t, testCases)
@@ -59,6 +102,94 @@ func TestBooleanExpression(t *testing.T) {
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
 		{"!(if (false) { 5; })", true},
+		{"1 <= 1", true},
+		{"1 <= 0", false},
+		{"2 >= 3", false},
+		{"3 >= 3", true},
+		{"1.5 <= 2", true},
+		{"2 >= 2.5", false},
+		{`"a" < "b"`, true},
+		{`"abc" > "abd"`, false},
+		{`"b" >= "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestLogicalOperators(t *testing.T) {
+	testCases := []vmTestCase{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"true || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"true && 5", true},
+		{"false || if (false) { 1 }", false},
+		{"false && (1 + true)", false},
+		{"true || (1 + true)", true},
+		{"let x = 1; false && (x = 2); x == 1", true},
+		{"let x = 1; true || (x = 2); x == 1", true},
+		{"let x = 1; true && (x = 2); x == 2", true},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestMatchExpressions(t *testing.T) {
+	describe := `
+	let describe = fn(v) {
+		match (v) {
+			0 => "zero",
+			-1 => "minus one",
+			"hi" => "greeting",
+			true => "yes",
+			[] => "empty",
+			[x] => "one " + x,
+			[1, [a, _]] if a > 2 => "nested",
+			[_, _] => "pair",
+			{"type": "point", "x": x, "y": y} => x + y,
+			{} => "hash",
+			2.5 => "float",
+			_ => "other",
+		}
+	};
+	`
+
+	testCases := []vmTestCase{
+		{describe + `describe(0)`, "zero"},
+		{describe + `describe(0.0)`, "zero"},
+		{describe + `describe(-1)`, "minus one"},
+		{describe + `describe("hi")`, "greeting"},
+		{describe + `describe(true)`, "yes"},
+		{describe + `describe(false)`, "other"},
+		{describe + `describe([])`, "empty"},
+		{describe + `describe(["a"])`, "one a"},
+		{describe + `describe([1, [3, 4]])`, "nested"},
+		{describe + `describe([1, [2, 4]])`, "pair"},
+		{describe + `describe([1, 2, 3])`, "other"},
+		{describe + `describe({"type": "point", "x": 1, "y": 2})`, 3},
+		{describe + `describe({"type": "point", "x": 1})`, "hash"},
+		{describe + `describe(2.5)`, "float"},
+		{describe + `describe(fn() {})`, "other"},
+		{"match (5) { 1 => 2 }", object.NullValue()},
+		{"match (5) { }", object.NullValue()},
+		{"match (5) { n if n > 10 => 1, n => n * 2 }", 10},
+		{"let n = 1; match ([2]) { [n] => n }; n", 1},
+		{"let x = 9; match (1) { x => x }; x", 9},
+		{"let x = 9; match (1) { x => x }", 1},
+		{"let x = 9; match ([1, 2]) { [x, 3] => 0, _ => x }", 9},
+		{"let f = fn(x) { match (1) { x => 0 }; x }; f(5)", 5},
+		{"let f = fn(x) { match ([1, 2]) { [x, 3] => 0, [_, x] => x } + x }; f(5)", 7},
+		{"let f = fn(x) { match (x) { 1 => if (true) { return 10 } }; 20 }; f(1) + f(2)", 30},
+		{"match (match (1) { 1 => [2] }) { [x] => match (x) { 2 => 3 } }", 3},
+		{"match ([1, 2]) { [a, b] if match (b) { 2 => false, _ => true } => 0, [a, _] => a }", 1},
+		{"let n = 0; for (x in [[1], [2, 3], 4]) { n += match (x) { [a] => a, [a, b] => a * b, _ => 0 } } n", 7},
 	}
 
 	runVMTests(t, testCases)
@@ -76,11 +207,187 @@ func TestConditional(t *testing.T) {
 		{"if (1 > 2) { 10 }", object.NullValue()},
 		{"if (false) { 10 }", object.NullValue()},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { let x = 1; }", object.NullValue()},
+		{"if (false) { 10 } else { }", object.NullValue()},
+		{"if (false) { 10 } else if (true) { 20 } else { 30 }", 20},
+		{"if (false) { 10 } else if (false) { 20 } else { 30 }", 30},
+		{"if (false) { 10 } else if (1 > 2) { 20 }", object.NullValue()},
+		{"let x = 3; if (x == 1) { 10 } else if (x == 2) { 20 } else if (x == 3) { 30 } else { 40 }", 30},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestLoops(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let i = 0; while (i < 5) { let i = i + 1; } i", 5},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 2) { break; } } i", 3},
+		{"let n = 0; for (let i = 0; i < 4; let i = i + 1) { let n = n + i; } n", 6},
+		{"let n = 0; for (let i = 0; i < 6; let i = i + 1) { if (i < 3) { continue; } let n = n + i; } n", 12},
+		{"let n = 0; for (x in [1, 2, 3]) { let n = n + x; } n", 6},
+		{"let n = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break; } let n = n + x; } n", 3},
+		{`let n = 0; for (c in "héllo") { let n = n + 1; } n`, 5},
+		{`let s = ""; for (k in {"b": 2, "c": 3, "a": 1}) { let s = s + k; } s`, "abc"},
+		{"let n = 0; for (x in [1, 2]) { for (y in [10, 20]) { if (y > 10) { break; } let n = n + x * y; } } n", 30},
+		{"let sum = fn(arr) { let n = 0; for (x in arr) { let n = n + x; } n }; sum([4, 5])", 9},
+		{"let f = fn() { while (true) { return 7; } return 1; }; f();", 7},
+		{"let f = fn() { for (;;) { break; } }; f();", object.NullValue()},
+		{"let n = 0; for (let i = 0; i < 10000; let i = i + 1) { let n = n + 1; } n", 10000},
+		{"let a = 1; let a = a + 1; a", 2},
+		{"let i = 0; while (i < 3) { i += 1 }; i", 3},
+		{"let n = 0; for (x in [1, 2]) { n += x }; n", 3},
+		{`for (x in {"a": 1}) { x }`, object.NullValue()},
+		{"let i = 0; while (i < 3000) { i = i + 1; let a = [1, 2, if (true) { continue }]; }; i", 3000},
+		{"let f = fn() { let i = 0; while (i < 3000) { i = i + 1; let a = [1, 2, if (true) { continue }]; }; i }; f()", 3000},
+		{"let n = 0; for (let i = 0; i < 3000; i += 1) { n += [1, if (true) { continue }][0] }; n", 0},
+		{"let n = 0; for (x in [1, 2, 3]) { n += len([x, if (x == 2) { break }]) }; n", 2},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestForInReleasesIterator(t *testing.T) {
+	inputs := []string{
+		"for (x in [1, 2]) { x }",
+		"for (x in [1, 2]) { break }",
+	}
+
+	for _, input := range inputs {
+		compiler := compile.NewCompilerWithBuiltins([]object.Object{})
+		err := compiler.Compile(test.Parse(input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(compiler.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		// the iterator is the first global
+		if vm.globals[0] != object.NullValue() {
+			t.Errorf("iterator not released after %q. got=%T (%+v)", input, vm.globals[0], vm.globals[0])
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let x = 1; x = 5; x", 5},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 1; x -= 2; x", -1},
+		{"let x = 3; x *= 2; x", 6},
+		{"let x = 7; x /= 2; x", 3},
+		{"let x = 1; let y = (x = 7); x + y", 14},
+		{"let a = 1; let b = 2; a = b = 3; a + b", 6},
+		{"let i = 0; while (i < 5) { i += 1; } i", 5},
+		{"let n = 0; for (let i = 0; i < 4; i += 1) { n += i; } n", 6},
+		{"let x = 1; let f = fn() { x = 10; }; f(); x", 10},
+		{"let f = fn(x) { x += 1; x }; f(1)", 2},
+		{"let f = fn() { let x = 1; let g = fn() { x }; x = 2; g() }; f()", 2},
+		{"let f = fn() { let x = 1; let g = fn() { x = 5 }; g(); x }; f()", 5},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c()", 3},
+		{
+			`
+			let counter = fn() { let n = 0; fn() { n += 1; n } };
+			let a = counter();
+			let b = counter();
+			a(); a(); b();
+			a() * 10 + b()
+			`,
+			32,
+		},
+		{
+			`
+			let f = fn() {
+				let x = 0;
+				let g = fn() { fn() { x += 1 } };
+				let inc = g();
+				inc(); inc();
+				x
+			};
+			f()
+			`,
+			2,
+		},
 	}
 
 	runVMTests(t, testCases)
 }
 
+func TestLoopErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, ...rest] = [1, 2, 3]; rest", []int{2, 3}},
+		{"let [...rest] = [1, 2]; rest", []int{1, 2}},
+		{"let [a, b, ...rest] = [1, 2]; rest", []int{}},
+		{"let [] = []; 1", 1},
+		{`let {name, age} = {"name": "x", "age": 3, "other": 4}; age`, 3},
+		{`let {} = {"a": 1}; 1`, 1},
+		{`let f = fn(p) { let [x, ...xs] = p; let {k} = {"k": x}; k + len(xs) }; f([5, 6, 7])`, 7},
+		{"let a = 1; let [a, b] = [a + 1, a + 2]; a + b", 5},
+		{"let [a, b] = [1, 2]; let [a, b] = [b, a]; a * 10 + b", 21},
+		{"let f = fn() { let [a, b] = [1, 2]; fn() { a + b } }; f()()", 3},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let [a, b] = 1", "cannot destructure INTEGER as ARRAY"},
+		{"let [a, b] = [1, 2, 3]", "wrong number of elements to destructure: want=2, got=3"},
+		{"let [a, b, ...c] = [1]", "not enough elements to destructure: want at least 2, got 1"},
+		{"let {a} = [1]", "cannot destructure ARRAY as HASH"},
+		{`let {a, b} = {"a": 1}`, "cannot destructure missing key: b"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
+func TestIndexAssignment(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; a[2] += 10; a[2]", 13},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 9; a[0]", 9},
+		{"let a = [1, 2, 3]; a[0] = a[1] = 7; a[0] + a[1]", 14},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, 2},
+		{`let h = {}; h["b"] = 3; h["b"] *= 2; h["b"]`, 6},
+		{`let h = {}; h[1] = 4; h[1]`, 4},
+		{"let a = [0, 0, 0]; for (let i = 0; i < 3; i += 1) { a[i] = i * i; } a[2]", 4},
+		{"let a = [[1], [2]]; a[1][0] = 5; a[1][0]", 5},
+		{"let f = fn(a) { a[0] = 1; }; let a = [0]; f(a); a", []int{1}},
+		{`let h = {"a": 1}; h.a = 2; h["a"]`, 2},
+		{`let h = {}; h.b = 3; h.b *= 2; h.b`, 6},
+		{`let h = {"db": {}}; h.db.port = 80; h["db"]["port"]`, 80},
+		{"type P { x, y }; let p = P(1, 2); p.x += 10; p.x + p.y", 13},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestIndexAssignmentErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let a = [1]; a[5] = 2", "index out of range: 5 (length 1)"},
+		{"let a = [1]; a[-1] = 2", "index out of range: -1 (length 1)"},
+		{`"abc"[0] = "x"`, "index assignment not supported: STRING"},
+		{"let h = {}; h[fn(x) { x }] = 1", "unusable as hash key: CLOSURE"},
+		{"let a = [1]; a.x = 2", "index assignment not supported: ARRAY"},
+		{"type P { x }; P(1).y = 2", "P has no field y"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	testCases := []vmTestCase{
 		{"let one = 1; one", 1},
@@ -101,6 +408,17 @@ func TestStringExpressions(t *testing.T) {
 	runVMTests(t, testCases)
 }
 
+func TestStringInterpolation(t *testing.T) {
+	testCases := []vmTestCase{
+		{`let price = 2.5; let qty = 4; "total: ${price * qty}"`, "total: 10.0"},
+		{`"${1} + ${true} = ${[1, 2]}"`, "1 + true = [1, 2]"},
+		{`let name = "monkey"; "hi ${name}, ${"nested ${name}"}!"`, "hi monkey, nested monkey!"},
+		{`let f = fn(x) { "<${x}>" }; f(1)`, "<1>"},
+	}
+
+	runVMTests(t, testCases)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	testCases := []vmTestCase{
 		{"[]", []int{}},
@@ -147,11 +465,271 @@ func TestIndexExpressions(t *testing.T) {
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", object.NullValue()},
 		{"{}[0]", object.NullValue()},
+		{`"héllo"[1]`, "é"},
+		{`"世界"[1]`, "界"},
+		{`"héllo"[5]`, object.NullValue()},
+		{`{"foo": 5}.foo`, 5},
+		{`{"foo": 5}.bar`, object.NullValue()},
+		{`let config = {"db": {"port": 5432}}; config.db.port`, 5432},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	testCases := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][-3:-1]", []int{2, 3}},
+		{"[1, 2, 3][:]", []int{1, 2, 3}},
+		{"[1, 2, 3][2:1]", []int{}},
+		{"[1, 2, 3][-10:10]", []int{1, 2, 3}},
+		{"let a = [1, 2]; let b = a[:]; b[0] = 5; a", []int{1, 2}},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[-2:]`, "lo"},
+		{`"世界"[:1]`, "世"},
+		{`"abc"[5:]`, ""},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestSliceErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{"5[1:]", "slice operator not supported: INTEGER"},
+		{`[1, 2]["a":]`, "slice index must be INTEGER, got STRING"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
+func TestTryStatements(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let r = 0; try { throw 5 } catch (e) { r = e }; r", 5},
+		{`let f = fn() { throw "bad" }; let r = ""; try { f(); r = "ok" } catch (e) { r = e }; r`, "bad"},
+		{"let g = fn(x) { if (x == 0) { throw x }; g(x - 1) + 1 }; let r = -1; try { g(3) } catch (e) { r = e }; r", 0},
+		{`let r = 0; try { throw {"code": 7} } catch (e) { r = e["code"] }; r`, 7},
+		{"let n = 0; try { n = n + 1 } finally { n = n + 10 }; n", 11},
+		{"let n = 0; try { throw 1 } catch (e) { n = n + e } finally { n = n * 10 }; n", 10},
+		{"let n = 0; try { try { throw 2 } finally { n = 1 } } catch (e) { n = n + e }; n", 3},
+		{"let n = 0; try { try { throw 1 } catch (e) { throw e + 1 } finally { n = 10 } } catch (e) { n = n + e }; n", 12},
+		{"let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; f() + n", 6},
+		{"let n = 0; let f = fn() { try { throw 1 } catch (e) { return e } finally { n = 2 } }; f() + n", 3},
+		{"let f = fn() { try { throw 1 } catch (e) { return e + 1 } }; 10 + f()", 12},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let n = 0; while (true) { try { break } finally { n = n + 1 } } n", 1},
+		{"let n = 0; let i = 0; while (i < 3) { i = i + 1; try { continue } finally { n = n + i } } n", 6},
+		{"let n = 0; for (i in [1, 2, 3]) { try { throw i } catch (e) { n = n + e } } n", 6},
+		{"let f = fn() { try { 1 } catch (e) { 2 } }; f(); let r = 0; try { throw 3 } catch (e) { r = e }; r", 3},
+		{"let r = 0; try { try { throw 1 } catch (e) { r = 10 } } catch (e) { r = 20 }; r", 10},
+		{`let r = ""; try { 1 / 0 } catch (e) { r = e }; r`, "division by zero"},
+		{`let r = ""; try { [1] + 1 } catch (e) { r = e }; r`, "unsupported types for binary operation: ARRAY INTEGER"},
+		{`try { throw "boom" } catch (e) { "caught " + e }`, "caught boom"},
+		{"try { 5 } catch (e) { 0 }", 5},
+		{"let f = fn() { try { throw 1 } catch (e) { e } }; f() + 1", 2},
+		{"let f = fn() { try { 1 } finally { 2 } }; f()", 1},
+		{"let n = 0; let f = fn() { try { throw 1 } catch (e) { e + 1 } finally { n = 10 } }; f() + n", 12},
+		{"if (true) { try { 3 } catch (e) { 4 } }", 3},
+		{"let e = 1; try { throw 2 } catch (e) { e }; e", 1},
+		{"let f = fn(e) { try { throw 2 } catch (e) { e }; e }; f(1)", 1},
+		{"let i = 0; while (i < 3000) { i += 1; try { i } finally { continue } } i", 3000},
+		{"try { } catch (e) { 1 }", object.NullValue()},
 	}
 
 	runVMTests(t, testCases)
 }
 
+func TestTryErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{`let f = fn() { throw "boom" }; f()`, "uncaught exception: boom"},
+		{"try { throw 1 } finally { }", "uncaught exception: 1"},
+		{"try { throw 1 } catch (e) { e + true }", "unsupported types for binary operation: INTEGER BOOLEAN"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
+func TestRecordTypes(t *testing.T) {
+	point := `type Point {
+		x, y,
+		fn move(self, dx, dy) { Point(self.x + dx, self.y + dy) }
+		fn norm(self) { self.x * self.x + self.y * self.y }
+		fn scaled(self, k = 2) { self.move(self.x * (k - 1), self.y * (k - 1)) }
+	}
+	let p = Point(1, 2);
+	`
+
+	testCases := []vmTestCase{
+		{point + "p.x", 1},
+		{point + "p.y", 2},
+		{point + `p["x"] + p["y"]`, 3},
+		{point + "p.move(2, 3).y", 5},
+		{point + "p.norm()", 5},
+		{point + "p.scaled().norm()", 20},
+		{point + "let m = p.move; m(1, 1).x", 2},
+		{point + `"${p}"`, "Point{x: 1, y: 2}"},
+		{point + `"${Point}"`, "type(Point)"},
+		{point + `"${p.norm}"`, "method(Point.norm)"},
+		{"let f = fn(n) { type Box { v, fn get(self) { self.v + n } } Box(1) }; f(10).get()", 11},
+		{"type Counter { n, fn next(self) { Counter(self.n + 1) } }; Counter(0).next().next().n", 2},
+		{"let f = fn() { type T { fn me(self) { T } } T }; let T = f(); T().me() == T", true},
+		{"type P { x, fn add(self, a, b) { self.x + a + b } }; [1, P(1).add(2, 3), 4]", []int{1, 6, 4}},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestPipelineExpressions(t *testing.T) {
+	helpers := `
+	let map = fn(xs, f) { let out = []; for (x in xs) { out = push(out, f(x)) } out };
+	let sum = fn(xs) { let s = 0; for (x in xs) { s += x } s };
+	`
+
+	testCases := []vmTestCase{
+		{helpers + "[1, 2, 3] |> map(fn(x) { x * 2 }) |> sum()", 12},
+		{helpers + "[1, 2] |> map(fn(x) { x + 1 })", []int{2, 3}},
+		{"1 + 2 |> fn(a, b) { a * b }(4)", 12},
+		{`"abc" |> len()`, 3},
+		{"type P { x, fn add(self, n) { self.x + n } }; 5 |> P(1).add()", 6},
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestRecordErrors(t *testing.T) {
+	testCases := []vmTestCase{
+		{"type P { x }; P(1).y", "P has no field y"},
+		{"type P { x, y }; P(1)", "wrong number of arguments: want=2, got=1"},
+		{"type P { x, fn m(self, a) { a } }; P(1).m()", "wrong number of arguments: want=2, got=1"},
+		{"let a = 1; a.x", "index operator not supported: INTEGER"},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
+func TestRecordEquality(t *testing.T) {
+	testCases := []vmTestCase{
+		{"type P { x }; P(1) == P(1)", true},
+		{"type P { x }; P(1) != P(1)", false},
+		{"type P { x }; P(1) == P(2)", false},
+		{"type P { x }; type Q { x }; P(1) == Q(1)", false},
+		{`type P { x, y }; P(1, P("a", true)) == P(1.0, P("a", true))`, true},
+		{"type P { x }; let a = [1]; P(a) == P(a)", true},
+		{"type P { x }; P([1]) == P([1])", false},
+	}
+
+	runVMTests(t, testCases)
+
+	// the evaluator has to agree with the VM
+	for _, tC := range testCases {
+		evaluated := eval.Eval(test.Parse(tC.input), object.NewEnvironment())
+		testExpectedObject(t, tC.expected, evaluated)
+	}
+}
+
+// modules are the files imported by the module tests
+var modules = map[string]string{
+	"lib/math.monkey": `
+import "util.monkey" as util
+let square = fn(x) { x * x }
+export let sumOfSquares = fn(a, b) { square(a) + square(b) }
+export let [first, ...others] = [1, 2, 3]
+export let read = fn() { util["state"][0] }
+export type Vec { x, y, fn dot(self, o) { self.x * o.x + self.y * o.y } }
+`,
+	"lib/util.monkey": `export let state = [0]`,
+	"lib/twice.monkey": `
+let twice = macro(x) { quote(unquote(x) + unquote(x)) }
+export let twiceIfLess = fn(x, y) { if (x < y) { twice(x) } else { y } }
+`,
+}
+
+func TestImportStatements(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []vmTestCase{
+		{`import "%s/lib/math.monkey" as m; m["sumOfSquares"](2, 3)`, 13},
+		{`import "%s/lib/math.monkey" as m; m["first"] * 10 + len(m["others"])`, 12},
+		{`import "%[1]s/lib/math.monkey" as m; import "%[1]s/lib/util.monkey" as u;
+		u["state"][0] = 7; m["read"]()`, 7},
+		{`let square = 2; import "%s/lib/math.monkey" as m; square`, 2},
+		{`import "%s/lib/math.monkey" as m; m.Vec(1, 2).dot(m.Vec(3, 4)) + m.sumOfSquares(1, 1)`, 13},
+	}
+	for i := range testCases {
+		testCases[i].input = fmt.Sprintf(testCases[i].input, dir)
+	}
+
+	runVMTests(t, testCases)
+}
+
+func TestModuleEquality(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []vmTestCase{
+		{`import "%[1]s/lib/util.monkey" as a; import "%[1]s/lib/../lib/util.monkey" as b; a == b`, true},
+		{`import "%[1]s/lib/util.monkey" as a; import "%[1]s/lib/util.monkey" as b; a != b`, false},
+		{`import "%[1]s/lib/util.monkey" as a; import "%[1]s/lib/math.monkey" as b; a == b`, false},
+	}
+	for i := range testCases {
+		testCases[i].input = fmt.Sprintf(testCases[i].input, dir)
+	}
+
+	runVMTests(t, testCases)
+
+	// the evaluator has to agree with the VM
+	for _, tC := range testCases {
+		evaluated := eval.Eval(test.Parse(tC.input), object.NewEnvironment())
+		testExpectedObject(t, tC.expected, evaluated)
+	}
+}
+
+func TestImportMacros(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	program := test.Parse(fmt.Sprintf(`import "%s/lib/twice.monkey" as t; t.twiceIfLess(2, 5) * 10 + t.twiceIfLess(6, 5)`, dir))
+
+	compiler := compile.NewCompilerWithBuiltins([]object.Object{})
+	compiler.SetMacroEvaluator(eval.EvalMacro)
+	err := compiler.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(compiler.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	testExpectedObject(t, 45, vm.LastPoppedStackElem())
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := test.WriteFiles(dir, modules); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []vmTestCase{
+		{
+			fmt.Sprintf(`import "%s/lib/math.monkey" as m; m["square"]`, dir),
+			fmt.Sprintf("%s/lib/math.monkey has no export square", dir),
+		},
+	}
+
+	runVMErrorTests(t, testCases)
+}
+
 func TestCallingFunctionsWithoutArguments(t *testing.T) {
 	testCases := []vmTestCase{
 		{
@@ -340,6 +918,27 @@ func TestCallingFunctionsWithArgumentsAndBindings(t *testing.T) {
 	runVMTests(t, testCases)
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	testCases := []vmTestCase{
+		{"let f = fn(a, b = 10) { a + b }; f(1)", 11},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", 3},
+		{"let f = fn(a = 1, b = a + 1) { a * 10 + b }; f()", 12},
+		{"let f = fn(a = 1, b = a + 1) { a * 10 + b }; f(5)", 56},
+		{"let f = fn(a = 1, b = a + 1) { a * 10 + b }; f(5, 0)", 50},
+		{"let f = fn(...xs) { xs }; f()", []int{}},
+		{"let f = fn(...xs) { xs }; f(1, 2, 3)", []int{1, 2, 3}},
+		{"let f = fn(a, ...xs) { xs }; f(1)", []int{}},
+		{"let f = fn(a, b = 2, ...xs) { [a, b, len(xs)] }; f(1)", []int{1, 2, 0}},
+		{"let f = fn(a, b = 2, ...xs) { xs }; f(1, 3, 4, 5)", []int{4, 5}},
+		{"let f = fn(a, x = fn() { a }) { x() }; f(7)", 7},
+		{"let f = fn(n, acc = 1) { if (n < 2) { acc } else { f(n - 1, acc * n) } }; f(5)", 120},
+		{"let g = 5; let f = fn(a = g) { let b = 1; a + b }; f() + f(1)", 8},
+		{"let f = fn(...xs) { fn() { len(xs) } }; f(1, 2)()", 2},
+	}
+
+	runVMTests(t, testCases)
+}
+
 func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	testCases := []vmTestCase{
 		{
@@ -354,6 +953,14 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input:    `fn(a, b) { a + b; }(1);`,
 			expected: `wrong number of arguments: want=2, got=1`,
 		},
+		{
+			input:    `fn(a, b = 1) { a + b; }(1, 2, 3);`,
+			expected: `wrong number of arguments: want=1..2, got=3`,
+		},
+		{
+			input:    `fn(a, ...b) { a; }();`,
+			expected: `wrong number of arguments: want=1.., got=0`,
+		},
 	}
 
 	for _, tt := range testCases {
@@ -382,6 +989,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("héllo, 世界")`, 9},
+		{`len(rest([1, 2, 3]))`, 2},
+		{`let f = fn(a) { first(rest(a)) }; f([1, 2])`, 2},
 		{
 			`len(1)`,
 			object.Error("argument to `len` not supported, got INTEGER"),
@@ -417,6 +1027,29 @@ func TestBuiltinFunctions(t *testing.T) {
 	runVMTests(t, testCases)
 }
 
+func TestBuiltinCallsPopArguments(t *testing.T) {
+	runVMTests(t, []vmTestCase{
+		{`len([1]) + len("ab")`, 3},
+		{"let f = fn() { len([1, 2]) * 10 + first([3]) }; f()", 23},
+	})
+
+	compiler := compile.NewCompilerWithBuiltins([]object.Object{})
+	err := compiler.Compile(test.Parse(`len([1, 2]); first([3]); rest([1, 2]); len("abc")`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(compiler.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if vm.sp != 0 {
+		t.Errorf("stack not balanced after builtin calls. sp=%d", vm.sp)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -566,6 +1199,31 @@ func runVMTests(t *testing.T, testCases []vmTestCase) {
 	}
 }
 
+// runVMErrorTests runs each input and expects the VM to fail with the error
+// message given as expected
+func runVMErrorTests(t *testing.T, testCases []vmTestCase) {
+	t.Helper()
+
+	for _, tC := range testCases {
+		program := test.Parse(tC.input)
+
+		compiler := compile.NewCompilerWithBuiltins([]object.Object{})
+		err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(compiler.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error for %q but resulted in none.", tC.input)
+		}
+		if err.Error() != tC.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tC.expected, err)
+		}
+	}
+}
+
 //gocyclo:ignore
 func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
 	t.Helper()
@@ -576,11 +1234,34 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testIntegerObject failed: %s", err)
 		}
+	case float64:
+		result, ok := actual.(object.Float)
+		if !ok {
+			t.Errorf("object is not Float: %T (%+v)", actual, actual)
+			return
+		}
+		if float64(result) != expected {
+			t.Errorf("object has wrong value. got=%g, want=%g", result, expected)
+		}
 	case bool:
 		err := testBooleanObject(expected, actual)
 		if err != nil {
 			t.Errorf("testBooleanObject failed: %s", err)
 		}
+	case string:
+		err := testStringObject(expected, actual)
+		if err != nil {
+			t.Errorf("testStringObject failed: %s", err)
+		}
+	case object.Error:
+		errObj, ok := actual.(object.Error)
+		if !ok {
+			t.Errorf("object is not Error: %T (%+v)", actual, actual)
+			return
+		}
+		if errObj != expected {
+			t.Errorf("wrong error message. want=%q, got=%q", expected, errObj)
+		}
 	case *object.Null:
 		if actual != object.NullValue() {
 			t.Errorf("object is not Null: %T (%+v)", actual, actual)
@@ -628,6 +1309,21 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 	}
 }
 
+func testStringObject(expected string, actual object.Object) error {
+	result, ok := actual.(object.String)
+	if !ok {
+		return fmt.Errorf("object is not String. got=%T (%+v)",
+			actual, actual)
+	}
+
+	if string(result) != expected {
+		return fmt.Errorf("object has wrong value. got=%q, want=%q",
+			result, expected)
+	}
+
+	return nil
+}
+
 func testBooleanObject(expected bool, actual object.Object) error {
 	result, ok := actual.(object.Boolean)
 	if !ok {